@@ -0,0 +1,127 @@
+package hedgehog
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// backoffMinAttempts defines min number of attempts within the window required to evaluate attempts failure ratio.
+const backoffMinAttempts = 5
+
+// backoffMaxSteps defines max number of compounded delay multiplications.
+const backoffMaxSteps = 4
+
+type errorBackoff struct {
+	threshold float64
+	factor    float64
+	window    time.Duration
+	now       func() time.Time
+}
+
+// WithErrorBackoff enables error rate adaptive hedged calls delay, so hedging backs off when resources start failing.
+// Each resource attempts failure ratio is tracked over provided sliding window, while it exceeds provided threshold
+// the resource effective delay is multiplied by provided factor once per each window tenth, compounding up to factor^4,
+// once the failure ratio drops back to the threshold the multiplier is divided by the factor the same way until it's 1.
+// Attempts canceled by hedged transport are not accounted, failure ratio is evaluated only over 5 attempts or more.
+// Delay is widened only for resources created by this package and server policy delays, per call delay is never widened.
+// Factor not greater than 1 means no backoff.
+func WithErrorBackoff(threshold float64, factor float64, window time.Duration) TransportOption {
+	return withErrorBackoff(threshold, factor, window, time.Now)
+}
+
+func withErrorBackoff(threshold float64, factor float64, window time.Duration, now func() time.Time) TransportOption {
+	return func(t *transport) {
+		t.backoff = &errorBackoff{threshold: threshold, factor: factor, window: window, now: now}
+	}
+}
+
+type backoffBucket struct {
+	epoch    int64
+	attempts uint64
+	failures uint64
+}
+
+type backoff struct {
+	threshold float64
+	factor    float64
+	size      time.Duration
+	now       func() time.Time
+	lock      sync.Mutex
+	buckets   [budgetBuckets]backoffBucket
+	steps     int
+	at        int64
+}
+
+func (eb *errorBackoff) new() *backoff {
+	size := eb.window / budgetBuckets
+	if size <= 0 {
+		size = 1
+	}
+	return &backoff{threshold: eb.threshold, factor: eb.factor, size: size, now: eb.now}
+}
+
+// backoffOf returns provided resource position backoff, nil backoff is returned if error backoff is disabled.
+func (t *transport) backoffOf(i int) *backoff {
+	if t.backoffs == nil {
+		return nil
+	}
+	return t.backoffs[i]
+}
+
+// record records single attempt outcome, nil backoff records nothing.
+func (b *backoff) record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	epoch := b.epoch()
+	b.adjust(epoch)
+	bk := &b.buckets[epoch%budgetBuckets]
+	if bk.epoch != epoch {
+		*bk = backoffBucket{epoch: epoch}
+	}
+	bk.attempts++
+	if failed {
+		bk.failures++
+	}
+}
+
+// multiplier returns current delay multiplier, nil backoff multiplier is always 1.
+func (b *backoff) multiplier() float64 {
+	if b == nil || b.factor <= 1 {
+		return 1
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.adjust(b.epoch())
+	return math.Pow(b.factor, float64(b.steps))
+}
+
+// adjust moves the multiplier by single step once per window bucket depending on the failure ratio.
+func (b *backoff) adjust(epoch int64) {
+	if epoch == b.at {
+		return
+	}
+	b.at = epoch
+	var attempts, failures uint64
+	for _, bk := range b.buckets {
+		if d := epoch - bk.epoch; d >= 0 && d < budgetBuckets {
+			attempts += bk.attempts
+			failures += bk.failures
+		}
+	}
+	switch {
+	case attempts >= backoffMinAttempts && float64(failures) > b.threshold*float64(attempts):
+		if b.steps < backoffMaxSteps {
+			b.steps++
+		}
+	case b.steps > 0:
+		b.steps--
+	}
+}
+
+func (b *backoff) epoch() int64 {
+	return b.now().UnixNano() / int64(b.size)
+}
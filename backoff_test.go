@@ -0,0 +1,62 @@
+package hedgehog
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithErrorBackoff(t *testing.T) {
+	var failing int64 = 1
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if atomic.LoadInt64(&failing) == 1 {
+			return nil, errors.New("upstream timeout")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	var delay int64
+	obs := ObserverFunc(func(e Event) {
+		if e.Kind == EventDelay {
+			atomic.StoreInt64(&delay, int64(e.Delay))
+		}
+	})
+	clock := newClock()
+	rt := NewTransport(
+		internal,
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_10, http.StatusOK)),
+		WithTransportObserver(obs),
+		withErrorBackoff(0.5, 2, 10*time.Second, clock.now),
+	)
+	roundTrips := func(n int) {
+		for i := 0; i < n; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			_, _ = rt.RoundTrip(req)
+		}
+	}
+	// failure burst inflates effective delay step by step up to the cap.
+	roundTrips(10)
+	for _, expected := range []time.Duration{20, 40, 80, 160, 160} {
+		clock.advance(time.Second)
+		roundTrips(1)
+		if d := time.Duration(atomic.LoadInt64(&delay)); d != expected*time.Millisecond {
+			t.Fatalf("expected effective delay %v during failure burst but got %v", expected*time.Millisecond, d)
+		}
+	}
+	// recovered error rate deflates effective delay step by step back to normal.
+	atomic.StoreInt64(&failing, 0)
+	roundTrips(100)
+	for _, expected := range []time.Duration{80, 40, 20, 10, 10} {
+		clock.advance(time.Second)
+		roundTrips(1)
+		if d := time.Duration(atomic.LoadInt64(&delay)); d != expected*time.Millisecond {
+			t.Fatalf("expected effective delay %v after recovery but got %v", expected*time.Millisecond, d)
+		}
+	}
+	stats, _ := GetStats(rt)
+	if b := stats.Resources[0].Backoff; b != 1 {
+		t.Fatalf("expected resource backoff multiplier 1 but got %v", b)
+	}
+}
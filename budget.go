@@ -0,0 +1,121 @@
+package hedgehog
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// budgetBuckets defines number of buckets in budget sliding window.
+const budgetBuckets = 10
+
+type budgetBucket struct {
+	epoch     int64
+	primaries uint64
+	hedges    uint64
+}
+
+// Budget defines named hedged calls budget that could be shared by multiple resources.
+// Budget allows hedged calls only while their number stays within provided max ratio of original calls
+// made for all resources sharing the budget over sliding window.
+type Budget struct {
+	name      string
+	maxRatio  float64
+	size      time.Duration
+	now       func() time.Time
+	lock      sync.Mutex
+	buckets   [budgetBuckets]budgetBucket
+	throttled uint64
+}
+
+// NewHedgeBudget returns new named hedged calls budget with provided max ratio of hedged calls to original calls
+// over provided sliding window.
+func NewHedgeBudget(name string, maxRatio float64, window time.Duration) *Budget {
+	return newHedgeBudget(name, maxRatio, window, time.Now)
+}
+
+func newHedgeBudget(name string, maxRatio float64, window time.Duration, now func() time.Time) *Budget {
+	size := window / budgetBuckets
+	if size <= 0 {
+		size = 1
+	}
+	return &Budget{name: name, maxRatio: math.Abs(maxRatio), size: size, now: now}
+}
+
+// ResourceWithBudget makes the resource hedged calls debit provided budget,
+// hedged calls are skipped once the budget is exhausted.
+func ResourceWithBudget(b *Budget) ResourceOption {
+	return func(r *decorated) {
+		r.budget = b
+	}
+}
+
+func (b *Budget) bucket(epoch int64) *budgetBucket {
+	bk := &b.buckets[epoch%budgetBuckets]
+	if bk.epoch != epoch {
+		*bk = budgetBucket{epoch: epoch}
+	}
+	return bk
+}
+
+func (b *Budget) totals(epoch int64) (primaries, hedges uint64) {
+	for _, bk := range b.buckets {
+		if d := epoch - bk.epoch; d >= 0 && d < budgetBuckets {
+			primaries += bk.primaries
+			hedges += bk.hedges
+		}
+	}
+	return
+}
+
+func (b *Budget) epoch() int64 {
+	return b.now().UnixNano() / int64(b.size)
+}
+
+// Primary records original call, it's called by hedged transport for each original call of the resource using the budget.
+func (b *Budget) Primary() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.bucket(b.epoch()).primaries++
+}
+
+// Allow debits the budget for single hedged call if it's not exhausted,
+// it's called by hedged transport before each hedged call of the resource using the budget.
+func (b *Budget) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	epoch := b.epoch()
+	primaries, hedges := b.totals(epoch)
+	if float64(hedges+1) > b.maxRatio*float64(primaries) {
+		b.throttled++
+		return false
+	}
+	b.bucket(epoch).hedges++
+	return true
+}
+
+// refund credits the budget back for single hedged call debited by Allow that was never made, nil budget refunds nothing.
+func (b *Budget) refund() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if bk := b.bucket(b.epoch()); bk.hedges > 0 {
+		bk.hedges--
+	}
+}
+
+func (b *Budget) stats() BudgetStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	primaries, hedges := b.totals(b.epoch())
+	return BudgetStats{
+		Name:      b.name,
+		MaxRatio:  b.maxRatio,
+		Primaries: primaries,
+		Hedges:    hedges,
+		Remaining: math.Max(b.maxRatio*float64(primaries)-float64(hedges), 0),
+		Throttled: b.throttled,
+	}
+}
@@ -0,0 +1,52 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestResourceWithBudget(t *testing.T) {
+	clock := newClock()
+	shared := newHedgeBudget("shared", 0.5, time.Second, clock.now)
+	search := NewResourceWithOptions(
+		NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_0, http.StatusOK),
+		ResourceWithBudget(shared),
+	)
+	users := NewResourceWithOptions(
+		NewResourceStatic(http.MethodGet, regexp.MustCompile(`users`), ms_0, http.StatusOK),
+		ResourceWithBudget(shared),
+	)
+	rec := newRecorder(ms_1)
+	rt := NewRoundTripper(rec, 3, search, users)
+	call := func(path string, n int) {
+		for i := 0; i < n; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+		}
+	}
+	// saturate shared budget via search resource.
+	call("/search", 10)
+	if rec.calls["/search"] != 15 {
+		t.Fatalf("expected 15 search upstream calls but got %d", rec.calls["/search"])
+	}
+	// users resource hedges are throttled by shared budget too.
+	call("/users", 4)
+	if rec.calls["/users"] != 6 {
+		t.Fatalf("expected 6 users upstream calls but got %d", rec.calls["/users"])
+	}
+	stats, _ := GetStats(rt)
+	expected := BudgetStats{Name: "shared", MaxRatio: 0.5, Primaries: 14, Hedges: 7, Remaining: 0, Throttled: 14*3 - 7}
+	if stats.Budgets["shared"] != expected {
+		t.Fatalf("expected budget stats %+v but got %+v", expected, stats.Budgets["shared"])
+	}
+	// budget is refilled once window slides.
+	clock.advance(2 * time.Second)
+	call("/users", 2)
+	if rec.calls["/users"] != 9 {
+		t.Fatalf("expected 9 users upstream calls but got %d", rec.calls["/users"])
+	}
+}
@@ -0,0 +1,68 @@
+package hedgehog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithBodyBuffering enables in memory buffering of matched requests bodies that couldn't be replayed with `GetBody`,
+// so such requests could be hedged as well. Request body is read into memory up to provided max bytes,
+// requests with bodies exceeding the limit are not hedged and are simply passed to underlying transport with intact bodies.
+// Non positive max bytes means no buffering, by default buffering is disabled.
+func WithBodyBuffering(maxBytes int) TransportOption {
+	return func(t *transport) {
+		t.buffering = maxBytes
+	}
+}
+
+// WithMaxBodySize sets max request body content length of matched requests that are hedged,
+// requests with larger bodies are not hedged and are simply passed to underlying transport.
+// Requests with bodies that couldn't be replayed, e.g. streaming bodies of unknown length, are never hedged
+// unless they are buffered with `WithBodyBuffering`. Observers are notified of such requests by `EventBypass`.
+// Non positive max size means no limit.
+func WithMaxBodySize(maxBytes int64) TransportOption {
+	return func(t *transport) {
+		t.maxBody = maxBytes
+	}
+}
+
+// WithExpectContinue enables hedging of matched requests with `Expect: 100-continue` header, by default
+// such requests are not hedged and are simply passed to underlying transport, so their bodies are never uploaded twice.
+// Once enabled each original and hedged http call goes through its own continue flow independently
+// and sends its own body copy replayed with `GetBody`, so only requests with replayable bodies are hedged.
+func WithExpectContinue() TransportOption {
+	return func(t *transport) {
+		t.expect = true
+	}
+}
+
+// continued returns whether provided request expects continue response before its body is sent.
+func continued(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Expect"), "100-continue")
+}
+
+// buffer returns shallow copy of provided request with in memory buffered replayable body,
+// if provided request body exceeds provided limit it returns request with intact body and false instead.
+func buffer(req *http.Request, limit int) (*http.Request, bool, error) {
+	if req.ContentLength > int64(limit) {
+		return req, false, nil
+	}
+	b, err := io.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+	if err != nil {
+		_ = req.Body.Close()
+		return nil, false, err
+	}
+	r := *req
+	if len(b) > limit {
+		r.Body = replayBody{Reader: io.MultiReader(bytes.NewReader(b), req.Body), Closer: req.Body}
+		return &r, false, nil
+	}
+	_ = req.Body.Close()
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	r.Body, _ = r.GetBody()
+	return &r, true, nil
+}
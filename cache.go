@@ -0,0 +1,166 @@
+package hedgehog
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// staleCacheBodyLimit defines max response body size that is stored in stale cache.
+const staleCacheBodyLimit = 1 << 20
+
+// CachedResponse defines http response stored in stale cache.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time
+}
+
+// Cache defines minimal stale responses cache.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Put(key string, resp *CachedResponse)
+}
+
+// WithStaleCache enables stale-if-error fallback for hedged transport using provided cache.
+// Winning responses for safe http methods (GET and HEAD) with body not bigger than 1MiB are stored in the cache
+// with http method and full url as the key; response is stored only once the caller reads its body till the end.
+// Only responses that passed the resource check strictly are stored, so neither soft check violations
+// nor not modified responses to conditional requests are ever served as stale responses.
+// When all hedged calls failed and the cache has response for the request, the transport returns copy of cached response
+// marked with `Warning` and `Age` headers instead of the error.
+func WithStaleCache(c Cache) TransportOption {
+	return func(t *transport) {
+		t.cache = c
+	}
+}
+
+func cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+// store stores provided winning response in the cache once its body is read by the caller till the end,
+// responses with body bigger than the limit or with body that is never read till the end are not stored.
+func (t *transport) store(key string, req *http.Request, resp *http.Response) {
+	if resp.ContentLength > staleCacheBodyLimit {
+		return
+	}
+	header := resp.Header.Clone()
+	put := func(body []byte) {
+		t.cache.Put(key, &CachedResponse{StatusCode: resp.StatusCode, Header: header, Body: body, StoredAt: time.Now()})
+	}
+	if req.Method == http.MethodHead {
+		put(nil)
+		return
+	}
+	resp.Body = &cacheBody{ReadCloser: resp.Body, put: put}
+}
+
+// cacheBody defines response body that tees read bytes up to the cache body limit
+// and stores them in the cache once the body is read till the end.
+type cacheBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	over bool
+	done bool
+	put  func([]byte)
+}
+
+func (b *cacheBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.over {
+		if b.buf.Len()+n > staleCacheBodyLimit {
+			b.over, b.buf = true, bytes.Buffer{}
+		} else {
+			_, _ = b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.over && !b.done {
+		b.done = true
+		b.put(b.buf.Bytes())
+	}
+	return n, err
+}
+
+func staleResponse(req *http.Request, cached *CachedResponse) *http.Response {
+	header := make(http.Header, len(cached.Header)+2)
+	for k, v := range cached.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	age := int64(time.Since(cached.StoredAt) / time.Second)
+	header.Set("Age", strconv.FormatInt(age, 10))
+	header.Add("Warning", `111 - "Revalidation Failed"`)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cached.StatusCode, http.StatusText(cached.StatusCode)),
+		StatusCode:    cached.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
+
+type lru struct {
+	capacity int
+	lock     sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type lruEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+// NewLRUCache returns new in memory stale responses cache that keeps up to provided capacity of the latest used responses.
+func NewLRUCache(capacity int) Cache {
+	return &lru{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *lru) Get(key string) (*CachedResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(lruEntry).resp, true
+}
+
+func (c *lru) Put(key string, resp *CachedResponse) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = lruEntry{key: key, resp: resp}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(lruEntry{key: key, resp: resp})
+	// in case of overflow: just drop the least recently used response.
+	if c.order.Len() > c.capacity {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(lruEntry).key)
+	}
+}
@@ -0,0 +1,215 @@
+package hedgehog
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithStaleCache(t *testing.T) {
+	ttable := map[string]struct {
+		method string
+		fresh  bool
+		code   int
+		body   string
+		stale  bool
+		err    error
+	}{
+		"should return stale cached response on total failure": {
+			method: http.MethodGet,
+			fresh:  true,
+			code:   http.StatusOK,
+			body:   "fresh",
+			stale:  true,
+		},
+		"should return error on total failure and cache miss": {
+			method: http.MethodGet,
+			err:    ErrResourceUnexpectedResponseCode{StatusCode: http.StatusServiceUnavailable},
+		},
+		"should return error on total failure for non cacheable method": {
+			method: http.MethodPost,
+			fresh:  true,
+			err:    ErrResourceUnexpectedResponseCode{StatusCode: http.StatusServiceUnavailable},
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			t.Parallel()
+			var healthy int64 = 1
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if atomic.LoadInt64(&healthy) == 0 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = io.WriteString(w, "fresh")
+			}))
+			defer srv.Close()
+			rt := NewTransport(
+				http.DefaultTransport,
+				WithCalls(1),
+				WithResources(NewResourceStatic(tcase.method, regexp.MustCompile(``), ms_1, http.StatusOK)),
+				WithStaleCache(NewLRUCache(8)),
+			)
+			if tcase.fresh {
+				req, _ := http.NewRequest(tcase.method, srv.URL+"/profile", nil)
+				resp, err := rt.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("expected nil err but got %v", err)
+				}
+				b, _ := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				if string(b) != "fresh" || resp.Header.Get("Warning") != "" {
+					t.Fatalf("expected fresh response but got %q %v", string(b), resp.Header)
+				}
+			}
+			atomic.StoreInt64(&healthy, 0)
+			req, _ := http.NewRequest(tcase.method, srv.URL+"/profile", nil)
+			resp, err := rt.RoundTrip(req)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if err != nil {
+				return
+			}
+			b, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if resp.StatusCode != tcase.code || string(b) != tcase.body {
+				t.Fatalf("expected response %d %q but got %d %q", tcase.code, tcase.body, resp.StatusCode, string(b))
+			}
+			if tcase.stale != (resp.Header.Get("Warning") != "" && resp.Header.Get("Age") != "") {
+				t.Fatalf("expected stale response headers but got %v", resp.Header)
+			}
+			if resp.Header.Get("Content-Type") != "text/plain" || resp.ContentLength != int64(len(tcase.body)) || resp.Request != req {
+				t.Fatalf("expected well formed stale response but got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestWithStaleCacheStreaming(t *testing.T) {
+	ttable := map[string]struct {
+		read   bool
+		stored bool
+	}{
+		"should store response once its body is read till the end": {
+			read:   true,
+			stored: true,
+		},
+		"should not store response with body that is not read till the end": {},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				// response body is streamed slowly after response headers are received.
+				r, w := io.Pipe()
+				go func() {
+					for i := 0; i < 5; i++ {
+						time.Sleep(ms_10)
+						_, _ = io.WriteString(w, "hedgehog")
+					}
+					_ = w.Close()
+				}()
+				return &http.Response{StatusCode: http.StatusOK, Body: r, ContentLength: -1, Request: req}, nil
+			})
+			cache := NewLRUCache(1)
+			rt := NewTransport(
+				internal,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_20, http.StatusOK)),
+				WithStaleCache(cache),
+			)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > ms_20 {
+				t.Fatalf("expected response before its body is streamed but took %v", elapsed)
+			}
+			if tcase.read {
+				b, err := io.ReadAll(resp.Body)
+				if err != nil || string(b) != strings.Repeat("hedgehog", 5) {
+					t.Fatalf("expected streamed body but got %q and %v", string(b), err)
+				}
+			}
+			_ = resp.Body.Close()
+			c, ok := cache.Get(cacheKey(req))
+			if ok != tcase.stored {
+				t.Fatalf("expected response stored %v but got %v", tcase.stored, ok)
+			}
+			if ok && string(c.Body) != strings.Repeat("hedgehog", 5) {
+				t.Fatalf("expected stored body but got %q", string(c.Body))
+			}
+		})
+	}
+}
+
+func TestWithStaleCacheUntrusted(t *testing.T) {
+	ttable := map[string]struct {
+		code   int
+		header http.Header
+		opts   []ResourceOption
+	}{
+		"should not store response accepted only by soft check": {
+			code: http.StatusInternalServerError,
+			opts: []ResourceOption{ResourceWithSoftCheck()},
+		},
+		"should not store not modified response to conditional request": {
+			code:   http.StatusNotModified,
+			header: http.Header{"If-None-Match": {`"v1"`}},
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: tcase.code, Body: io.NopCloser(strings.NewReader("hedgehog")), Request: req}, nil
+			})
+			cache := NewLRUCache(1)
+			rs := NewResourceWithOptions(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_20, http.StatusOK), tcase.opts...)
+			rt := NewTransport(internal, WithCalls(1), WithResources(rs), WithStaleCache(cache))
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			for k, v := range tcase.header {
+				req.Header[k] = v
+			}
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if _, ok := cache.Get(cacheKey(req)); ok {
+				t.Fatal("expected response not to be stored")
+			}
+		})
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Put("a", &CachedResponse{StatusCode: 1})
+	c.Put("b", &CachedResponse{StatusCode: 2})
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected cache hit for a")
+	}
+	c.Put("c", &CachedResponse{StatusCode: 3})
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected least recently used b to be evicted")
+	}
+	if r, ok := c.Get("a"); !ok || r.StatusCode != 1 {
+		t.Fatalf("expected cache hit for a but got %v", r)
+	}
+	if r, ok := c.Get("c"); !ok || r.StatusCode != 3 {
+		t.Fatalf("expected cache hit for c but got %v", r)
+	}
+}
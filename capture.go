@@ -0,0 +1,126 @@
+package hedgehog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+)
+
+// AttemptDump defines single http call debug capture, request and response dumps are truncated to capture max bytes,
+// response dump contains response body only as far as it was read by the time the response body is closed.
+type AttemptDump struct {
+	Attempt  int
+	Start    time.Time
+	Elapsed  time.Duration
+	Request  []byte
+	Response []byte
+	Err      error
+}
+
+type capture struct {
+	max  int
+	sink func(AttemptDump)
+}
+
+// WithDebugCapture enables debug capture of each original and hedged http call of matched requests,
+// each call request and response dumps truncated to provided max bytes are handed to provided sink
+// once the call response body is closed or the call fails. Capturing never consumes response bodies,
+// instead response body is captured as it's read by its consumer. Request body is captured only
+// if request could be replayed with `GetBody`. Sink is called concurrently from multiple goroutines.
+// Non positive max bytes means no capture.
+func WithDebugCapture(maxBytes int, sink func(AttemptDump)) TransportOption {
+	return func(t *transport) {
+		if maxBytes <= 0 || sink == nil {
+			t.capture = nil
+			return
+		}
+		t.capture = &capture{max: maxBytes, sink: sink}
+	}
+}
+
+// start captures provided attempt request dump.
+func (c *capture) start(req *http.Request, attempt uint64) *dumper {
+	d := &dumper{capture: c, dump: AttemptDump{Attempt: int(attempt), Start: time.Now()}}
+	dreq, body := req, req.GetBody != nil
+	if body {
+		b, err := req.GetBody()
+		if err != nil {
+			body = false
+		} else {
+			dreq = req.Clone(req.Context())
+			dreq.Body = b
+		}
+	}
+	if dump, err := httputil.DumpRequestOut(dreq, body); err == nil {
+		d.dump.Request = c.truncate(dump)
+	}
+	return d
+}
+
+func (c *capture) truncate(b []byte) []byte {
+	if len(b) > c.max {
+		return b[:c.max]
+	}
+	return b
+}
+
+type dumper struct {
+	*capture
+	once sync.Once
+	lock sync.Mutex
+	dump AttemptDump
+}
+
+// finish captures provided attempt outcome, successful response body is replaced with capturing one
+// and the dump is flushed once it's closed, otherwise the dump is flushed right away.
+func (d *dumper) finish(resp *http.Response, err error) {
+	d.dump.Elapsed = time.Since(d.dump.Start)
+	if err != nil {
+		d.dump.Err = err
+		d.flush()
+		return
+	}
+	if dump, err := httputil.DumpResponse(resp, false); err == nil {
+		d.dump.Response = d.truncate(dump)
+	}
+	resp.Body = &capturedBody{ReadCloser: resp.Body, dumper: d}
+}
+
+// flush hands the dump to the sink exactly once.
+func (d *dumper) flush() {
+	d.once.Do(func() {
+		d.lock.Lock()
+		dump := d.dump
+		d.lock.Unlock()
+		d.sink(dump)
+	})
+}
+
+type capturedBody struct {
+	io.ReadCloser
+	dumper *dumper
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		d := b.dumper
+		d.lock.Lock()
+		if rest := d.max - len(d.dump.Response); rest > 0 {
+			if n < rest {
+				rest = n
+			}
+			d.dump.Response = append(d.dump.Response, p[:rest]...)
+		}
+		d.lock.Unlock()
+	}
+	return n, err
+}
+
+func (b *capturedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.dumper.flush()
+	return err
+}
@@ -0,0 +1,74 @@
+package hedgehog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithDebugCapture(t *testing.T) {
+	const max = 128
+	body := strings.Repeat("hedgehog", 128)
+	uri, stop := tdivserv([]string{body, body}, nil, []time.Duration{ms_50, ms_0})
+	defer stop()
+	var lock sync.Mutex
+	var dumps []AttemptDump
+	cli := &http.Client{Transport: NewTransport(
+		http.DefaultTransport,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_5, http.StatusOK)),
+		WithDebugCapture(max, func(d AttemptDump) {
+			lock.Lock()
+			defer lock.Unlock()
+			dumps = append(dumps, d)
+		}),
+	)}
+	resp, err := cli.Get(uri)
+	if err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != body {
+		t.Fatalf("expected intact response body of %d bytes but got %d bytes", len(body), len(b))
+	}
+	// wait for canceled original call to be captured in background.
+	for i := 0; i < 100; i++ {
+		lock.Lock()
+		n := len(dumps)
+		lock.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(ms_1)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(dumps) != 2 {
+		t.Fatalf("expected 2 attempt dumps but got %d", len(dumps))
+	}
+	sort.Slice(dumps, func(i, j int) bool { return dumps[i].Attempt < dumps[j].Attempt })
+	loser, winner := dumps[0], dumps[1]
+	if loser.Attempt != 0 || !errors.Is(loser.Err, context.Canceled) || loser.Response != nil {
+		t.Fatalf("expected canceled original call dump but got %+v", loser)
+	}
+	if winner.Attempt != 1 || winner.Err != nil || winner.Elapsed <= 0 {
+		t.Fatalf("expected successful hedged call dump but got %+v", winner)
+	}
+	for _, d := range dumps {
+		if !bytes.HasPrefix(d.Request, []byte("GET / HTTP/1.1")) || len(d.Request) > max {
+			t.Fatalf("expected truncated request dump but got %q", d.Request)
+		}
+	}
+	if !bytes.HasPrefix(winner.Response, []byte("HTTP/1.1 200 OK")) || len(winner.Response) != max {
+		t.Fatalf("expected response dump truncated at %d bytes but got %q", max, winner.Response)
+	}
+}
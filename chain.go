@@ -0,0 +1,54 @@
+package hedgehog
+
+import (
+	"net/http"
+)
+
+// RoundTripperFunc defines functional http round tripper adapter.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls the function with provided request.
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Wrapper returns http round tripper middleware that wraps provided round tripper with hedged transport
+// configured with provided options, see `NewTransport`.
+func Wrapper(opts ...TransportOption) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return NewTransport(rt, opts...)
+	}
+}
+
+// Chain returns http round tripper that wraps provided base round tripper with provided middlewares,
+// the first provided middleware becomes the outermost one and the last one wraps base round tripper directly.
+// Middlewares that mutate each outgoing request (e.g. auth) should be provided after hedged transport `Wrapper`,
+// so that they are applied to each hedged call separately, while middlewares that observe logical calls
+// (e.g. tracing) should be provided before it. If auth middleware can't wrap hedged transport,
+// e.g. oauth2.Transport is the outermost client transport, use `WithTokenSource` instead.
+// If nil base round tripper is provided default transport will be used.
+// If resulting chain contains more than one hedged transport (visible through `Unwrap`) it panics with `ErrTransportNested`.
+func Chain(base http.RoundTripper, wrappers ...func(http.RoundTripper) http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	rt := base
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		rt = wrappers[i](rt)
+	}
+	found := false
+	for depth, cur := 0, rt; cur != nil && depth < maxUnwrapDepth; depth++ {
+		if _, ok := cur.(*transport); ok {
+			if found {
+				panic(ErrTransportNested{Depth: depth})
+			}
+			found = true
+		}
+		u, ok := cur.(interface{ Unwrap() http.RoundTripper })
+		if !ok {
+			break
+		}
+		cur = u.Unwrap()
+	}
+	return rt
+}
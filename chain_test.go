@@ -0,0 +1,92 @@
+package hedgehog
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tauth(token string) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", token)
+			return rt.RoundTrip(req)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	hedge := Wrapper(
+		WithCalls(2),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_0, http.StatusOK)),
+	)
+	ttable := map[string]struct {
+		wrappers []func(http.RoundTripper) http.RoundTripper
+		tokens   []string
+		err      error
+	}{
+		"should sign each hedged call when auth wraps base transport": {
+			wrappers: []func(http.RoundTripper) http.RoundTripper{hedge, tauth("Bearer token")},
+			tokens:   []string{"Bearer token", "Bearer token", "Bearer token"},
+		},
+		"should pass through without hedged transport": {
+			wrappers: []func(http.RoundTripper) http.RoundTripper{tauth("Bearer token")},
+			tokens:   []string{"Bearer token"},
+		},
+		"should panic on multiple hedged transports": {
+			wrappers: []func(http.RoundTripper) http.RoundTripper{
+				Wrapper(WithAllowNesting()),
+				hedge,
+				tauth("Bearer token"),
+			},
+			err: ErrTransportNested{Depth: 1},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var lock sync.Mutex
+			var tokens []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				lock.Lock()
+				tokens = append(tokens, req.Header.Get("Authorization"))
+				lock.Unlock()
+				time.Sleep(ms_10)
+			}))
+			defer srv.Close()
+			var err error
+			var rt http.RoundTripper
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						err = r.(error)
+					}
+				}()
+				rt = Chain(nil, tcase.wrappers...)
+			}()
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if err != nil {
+				return
+			}
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/profile", nil)
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			srv.Close()
+			if len(tokens) != len(tcase.tokens) {
+				t.Fatalf("expected upstream tokens %v but got %v", tcase.tokens, tokens)
+			}
+			for i := range tokens {
+				if tokens[i] != tcase.tokens[i] {
+					t.Fatalf("expected upstream tokens %v but got %v", tcase.tokens, tokens)
+				}
+			}
+		})
+	}
+}
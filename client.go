@@ -0,0 +1,78 @@
+package hedgehog
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientOption defines hedged http client option, see `NewHTTPClient`.
+// Options are applied in provided order, so later options override earlier ones.
+type ClientOption func(*client)
+
+type client struct {
+	internal http.RoundTripper
+	opts     []TransportOption
+}
+
+// NewHTTPClient returns shallow copy of provided http client with its transport wrapped with hedged transport
+// configured with provided options, provided http client itself is never modified. If nil client is provided
+// new empty client will be used, if nil transport is provided default transport will be used.
+// By default hedged transport makes no hedged calls and has no resources, see `ClientWithDefaults`.
+// If wrapped transport is already hedged it panics with `ErrTransportNested`.
+func NewHTTPClient(cli *http.Client, opts ...ClientOption) *http.Client {
+	var c http.Client
+	if cli != nil {
+		c = *cli
+	}
+	cfg := client{internal: c.Transport}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.internal == nil {
+		cfg.internal = http.DefaultTransport
+	}
+	c.Transport = NewTransport(cfg.internal, cfg.opts...)
+	return &c
+}
+
+// ClientWithRoundTripper sets hedged http client underlying transport that is wrapped with hedged transport
+// instead of provided http client transport. Nil transport means provided http client transport is used.
+// Provided transport is always wrapped, so hedged calls number and resources options still apply to it.
+func ClientWithRoundTripper(rt http.RoundTripper) ClientOption {
+	return func(c *client) {
+		c.internal = rt
+	}
+}
+
+// ClientWithCalls sets hedged http client transport hedged calls number, see `WithCalls`.
+func ClientWithCalls(calls uint64) ClientOption {
+	return ClientWithTransportOptions(WithCalls(calls))
+}
+
+// ClientWithResources sets hedged http client transport resources, see `WithResources`.
+// It replaces resources set by earlier options, including `ClientWithDefaults` resource.
+func ClientWithResources(resources ...Resource) ClientOption {
+	return ClientWithTransportOptions(func(t *transport) {
+		t.resources = append([]Resource(nil), resources...)
+	})
+}
+
+// ClientWithTransportOptions appends provided hedged transport options to hedged http client transport options,
+// e.g. `ClientWithTransportOptions(WithCalls(calls), WithResources(resources...))` builds the same client transport
+// as `NewRoundTripper(transport, calls, resources...)` does, the same as `ClientWithCalls` and `ClientWithResources` options.
+func ClientWithTransportOptions(opts ...TransportOption) ClientOption {
+	return func(c *client) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+// ClientWithDefaults sets hedged http client transport to make single hedged call for all GET requests
+// with percentiles resource that waits for p95 of successful responses latencies starting with flat 100ms,
+// see `NewResourcePercentiles`. It replaces hedged calls number and resources set by earlier options.
+func ClientWithDefaults() ClientOption {
+	return func(c *client) {
+		c.opts = append(c.opts, WithCalls(1), func(t *transport) {
+			t.resources = []Resource{NewResourcePercentiles(http.MethodGet, nil, 100*time.Millisecond, 0.95, 1000, http.StatusOK)}
+		})
+	}
+}
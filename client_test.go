@@ -0,0 +1,150 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestNewHTTPClientOptions(t *testing.T) {
+	rs := NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)
+	ttable := map[string]struct {
+		opts      []ClientOption
+		transport http.RoundTripper
+		calls     uint64
+		resources int
+		custom    bool
+	}{
+		"should make no hedged calls without options": {
+			transport: http.DefaultTransport,
+		},
+		"should wrap provided round tripper": {
+			opts:      []ClientOption{ClientWithRoundTripper(twrapper{internal: http.DefaultTransport})},
+			transport: twrapper{internal: http.DefaultTransport},
+		},
+		"should wrap last provided round tripper": {
+			opts: []ClientOption{
+				ClientWithRoundTripper(twrapper{internal: http.DefaultTransport}),
+				ClientWithRoundTripper(nil),
+			},
+			transport: http.DefaultTransport,
+		},
+		"should install defaults": {
+			opts:      []ClientOption{ClientWithDefaults()},
+			transport: http.DefaultTransport,
+			calls:     1,
+			resources: 1,
+		},
+		"should override defaults with later transport options": {
+			opts: []ClientOption{
+				ClientWithDefaults(),
+				ClientWithTransportOptions(WithCalls(3), WithResources(rs)),
+			},
+			transport: http.DefaultTransport,
+			calls:     3,
+			resources: 2,
+			custom:    true,
+		},
+		"should override transport options with later defaults": {
+			opts: []ClientOption{
+				ClientWithTransportOptions(WithCalls(3), WithResources(rs)),
+				ClientWithDefaults(),
+			},
+			transport: http.DefaultTransport,
+			calls:     1,
+			resources: 1,
+		},
+		"should apply transport options in order": {
+			opts: []ClientOption{
+				ClientWithTransportOptions(WithCalls(3), WithResources(rs)),
+				ClientWithTransportOptions(WithCalls(2)),
+			},
+			transport: http.DefaultTransport,
+			calls:     2,
+			resources: 1,
+			custom:    true,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			cli := NewHTTPClient(nil, tcase.opts...)
+			h, ok := cli.Transport.(*transport)
+			if !ok {
+				t.Fatalf("expected hedged client transport but got %T", cli.Transport)
+			}
+			if h.internal != tcase.transport {
+				t.Fatalf("expected hedged transport to wrap %v but got %v", tcase.transport, h.internal)
+			}
+			if h.calls != tcase.calls {
+				t.Fatalf("expected %d hedged calls but got %d", tcase.calls, h.calls)
+			}
+			if len(h.resources) != tcase.resources {
+				t.Fatalf("expected %d resources but got %d", tcase.resources, len(h.resources))
+			}
+			if tcase.resources == 0 {
+				return
+			}
+			if _, ok := h.resources[len(h.resources)-1].(static); ok != tcase.custom {
+				t.Fatalf("expected custom resource %t but got %T", tcase.custom, h.resources[len(h.resources)-1])
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientCalls(t *testing.T) {
+	profile := NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)
+	search := NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK)
+	ttable := map[string]struct {
+		opts  []ClientOption
+		rt    bool
+		calls int
+	}{
+		"should make four calls with three hedged calls": {
+			opts:  []ClientOption{ClientWithCalls(3), ClientWithResources(profile)},
+			calls: 4,
+		},
+		"should make four calls with three hedged calls over defaults": {
+			opts:  []ClientOption{ClientWithDefaults(), ClientWithCalls(3), ClientWithResources(profile)},
+			calls: 4,
+		},
+		"should make four calls with three hedged calls on provided round tripper": {
+			opts:  []ClientOption{ClientWithCalls(3), ClientWithResources(profile)},
+			rt:    true,
+			calls: 4,
+		},
+		"should override earlier hedged calls": {
+			opts:  []ClientOption{ClientWithCalls(3), ClientWithCalls(1), ClientWithResources(profile)},
+			calls: 2,
+		},
+		"should replace earlier resources": {
+			opts:  []ClientOption{ClientWithCalls(3), ClientWithResources(profile), ClientWithResources(search)},
+			calls: 1,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			internal := newRecorder(ms_50)
+			client := &http.Client{Transport: internal}
+			opts := tcase.opts
+			if tcase.rt {
+				client.Transport = RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+					t.Fatal("expected provided client transport not to be called")
+					return nil, nil
+				})
+				opts = append([]ClientOption{ClientWithRoundTripper(internal)}, opts...)
+			}
+			resp, err := NewHTTPClient(client, opts...).Get("http://example.com/profile")
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			internal.lock.Lock()
+			defer internal.lock.Unlock()
+			if calls := internal.calls["/profile"]; calls != tcase.calls {
+				t.Fatalf("expected %d calls but got %d", tcase.calls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package hedgehog
+
+import "net/http"
+
+// WithStrictNotModified makes hedged transport check not modified responses of conditional requests
+// with the resource check as any other response. By default not modified responses of requests carrying
+// conditional headers are always accepted, as they are legitimate responses to such requests,
+// and as with any other accepted responses the first received one wins whether it's not modified or not.
+func WithStrictNotModified() TransportOption {
+	return func(t *transport) {
+		t.checkUnmodified = true
+	}
+}
+
+// conditional returns whether provided request carries conditional headers that make not modified response legitimate.
+func conditional(req *http.Request) bool {
+	return req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+}
+
+// check checks provided response of provided request with provided resource check,
+// not modified responses of conditional requests are accepted unless strict not modified check is enabled.
+func (t *transport) check(rs Resource, req *http.Request, resp *http.Response) error {
+	err := rs.Check(resp)
+	if err != nil && !t.checkUnmodified && resp.StatusCode == http.StatusNotModified && conditional(req) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package hedgehog
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestConditionalNotModified(t *testing.T) {
+	ttable := map[string]struct {
+		codes       []int
+		allowed     []int
+		conditional bool
+		opts        []TransportOption
+		code        int
+		err         error
+	}{
+		"should accept not modified response of conditional request": {
+			codes:       []int{http.StatusNotModified, http.StatusOK},
+			allowed:     []int{http.StatusOK},
+			conditional: true,
+			code:        http.StatusNotModified,
+		},
+		"should accept only not modified responses of conditional request": {
+			codes:       []int{http.StatusNotModified, http.StatusNotModified},
+			allowed:     []int{http.StatusOK},
+			conditional: true,
+			code:        http.StatusNotModified,
+		},
+		"should reject not modified response of unconditional request": {
+			codes:   []int{http.StatusNotModified, http.StatusOK},
+			allowed: []int{http.StatusOK},
+			code:    http.StatusOK,
+		},
+		"should reject only not modified responses of unconditional request": {
+			codes:   []int{http.StatusNotModified, http.StatusNotModified},
+			allowed: []int{http.StatusOK},
+			err:     ErrResourceUnexpectedResponseCode{StatusCode: http.StatusNotModified},
+		},
+		"should accept not modified response of unconditional request allowed by resource": {
+			codes:   []int{http.StatusNotModified, http.StatusOK},
+			allowed: []int{http.StatusOK, http.StatusNotModified},
+			code:    http.StatusNotModified,
+		},
+		"should reject not modified response of conditional request with strict check": {
+			codes:       []int{http.StatusNotModified, http.StatusOK},
+			allowed:     []int{http.StatusOK},
+			conditional: true,
+			opts:        []TransportOption{WithStrictNotModified()},
+			code:        http.StatusOK,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempt, _ := AttemptFromContext(req.Context())
+				return &http.Response{StatusCode: tcase.codes[attempt], Body: http.NoBody, Request: req}, nil
+			})
+			rt := NewTransport(internal, append(
+				tcase.opts,
+				WithCalls(1),
+				WithHedgeOnFailure(),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_50, tcase.allowed...)),
+			)...)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			if tcase.conditional {
+				req.Header.Set("If-None-Match", `"v1"`)
+			}
+			resp, err := rt.RoundTrip(req)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if err == nil && resp.StatusCode != tcase.code {
+				t.Fatalf("expected response code %d but got %d", tcase.code, resp.StatusCode)
+			}
+		})
+	}
+}
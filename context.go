@@ -0,0 +1,150 @@
+package hedgehog
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type overrideKey struct{}
+
+type attemptKey struct{}
+
+// AttemptFromContext returns http call attempt launch order index carried by provided request context,
+// 0 for original call, 1..N for hedged calls, N+1..N+M for failure replacements and N+M+1 for failover call. It lets underlying transports tell hedged calls apart,
+// e.g. retrying transport could retry only original calls, so the worst case number of requests
+// made for single hedged request is bounded by original call retries plus hedged calls number.
+// It returns false for requests that are passed to underlying transport as is, they are never hedged.
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	attempt, ok := ctx.Value(attemptKey{}).(int)
+	return attempt, ok
+}
+
+// override defines per call hedging overrides carried by request context,
+// zero valued fields mean no override.
+type override struct {
+	calls    uint64
+	delay    time.Duration
+	deadline time.Duration
+	disable  bool
+	force    bool
+	observer Observer
+	control  *control
+}
+
+func overrideFrom(ctx context.Context) override {
+	o, _ := ctx.Value(overrideKey{}).(override)
+	return o
+}
+
+func withOverride(ctx context.Context, o override) context.Context {
+	return context.WithValue(ctx, overrideKey{}, o)
+}
+
+// CallOption defines per call hedging option.
+type CallOption func(*override)
+
+// CallWithCalls overrides transport hedged calls number for the call.
+func CallWithCalls(calls uint64) CallOption {
+	return func(o *override) {
+		o.calls = calls
+	}
+}
+
+// CallWithDelay overrides resource delay for the call.
+func CallWithDelay(delay time.Duration) CallOption {
+	return func(o *override) {
+		o.delay = delay
+	}
+}
+
+// CallWithSoftDeadline overrides transport soft deadline for the call, see `WithSoftDeadline`.
+func CallWithSoftDeadline(deadline time.Duration) CallOption {
+	return func(o *override) {
+		o.deadline = deadline
+	}
+}
+
+// CallWithoutHedging disables hedging for the call.
+func CallWithoutHedging() CallOption {
+	return func(o *override) {
+		o.disable = true
+	}
+}
+
+// CallWithForcedHedging makes the call launch all its hedged calls right away together with the original call
+// instead of waiting for the delay, hedged calls are still subject to hedged calls limits and budgets.
+func CallWithForcedHedging() CallOption {
+	return func(o *override) {
+		o.force = true
+	}
+}
+
+// WithoutHedging returns provided context copy that disables hedging for requests carrying it,
+// such requests are always passed to underlying transport as is even if they match some resource,
+// e.g. requests that look idempotent but aren't.
+func WithoutHedging(ctx context.Context) context.Context {
+	return withCallOptions(ctx, CallWithoutHedging())
+}
+
+// Override defines per request hedging override, zero valued fields mean no override.
+type Override struct {
+	Delay time.Duration
+	Calls uint64
+}
+
+// WithOverride returns provided context copy that overrides resource delay and transport hedged calls number
+// for requests carrying it, see `CallWithDelay` and `CallWithCalls`. Overridden requests still feed the resource hooks,
+// so the resource latency learning keeps track of all matched requests.
+func WithOverride(ctx context.Context, ov Override) context.Context {
+	opts := make([]CallOption, 0, 2)
+	if ov.Delay > 0 {
+		opts = append(opts, CallWithDelay(ov.Delay))
+	}
+	if ov.Calls > 0 {
+		opts = append(opts, CallWithCalls(ov.Calls))
+	}
+	return withCallOptions(ctx, opts...)
+}
+
+// ForceHedge returns provided context copy that forces hedging for requests carrying it, see `CallWithForcedHedging`.
+// It has no effect on requests that don't match any resource.
+func ForceHedge(ctx context.Context) context.Context {
+	return withCallOptions(ctx, CallWithForcedHedging())
+}
+
+func withCallOptions(ctx context.Context, opts ...CallOption) context.Context {
+	if len(opts) == 0 {
+		return ctx
+	}
+	o := overrideFrom(ctx)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return withOverride(ctx, o)
+}
+
+// control defines per request hedged calls stop handle.
+type control struct {
+	once sync.Once
+	stop chan struct{}
+}
+
+// stopped returns channel that is closed once hedged calls are stopped, nil control is never stopped.
+func (c *control) stopped() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.stop
+}
+
+// WithHedgeControl returns provided context copy together with stop function for requests carrying it.
+// Calling stop cancels all outstanding hedged calls and prevents new ones from being launched,
+// while the original call and provided context itself are left untouched, e.g. once the result is no longer latency sensitive.
+// Stop function is safe to call multiple times and concurrently.
+func WithHedgeControl(ctx context.Context) (context.Context, func()) {
+	c := &control{stop: make(chan struct{})}
+	return withCallOptions(ctx, func(o *override) { o.control = c }), func() {
+		c.once.Do(func() { close(c.stop) })
+	}
+}
@@ -0,0 +1,245 @@
+package hedgehog
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithoutHedging(t *testing.T) {
+	ttable := map[string]struct {
+		ctx   context.Context
+		calls int
+	}{
+		"should hedge request without marker": {
+			ctx:   context.Background(),
+			calls: 3,
+		},
+		"should not hedge request with marker": {
+			ctx:   WithoutHedging(context.Background()),
+			calls: 1,
+		},
+	}
+	for tname, tcase := range ttable {
+		ctx, calls := tcase.ctx, tcase.calls
+		t.Run(tname, func(t *testing.T) {
+			rec := newRecorder(ms_20)
+			var headers []http.Header
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				rec.lock.Lock()
+				headers = append(headers, req.Header.Clone())
+				rec.lock.Unlock()
+				return rec.RoundTrip(req)
+			})
+			rt := NewRoundTripper(internal, 2, NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK))
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/profile", nil)
+			req.Header.Set("Accept", "application/json")
+			// marker survives request cloning.
+			req = req.Clone(req.Context())
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			rec.lock.Lock()
+			defer rec.lock.Unlock()
+			if rec.calls["/profile"] != calls {
+				t.Fatalf("expected %d upstream calls but got %d", calls, rec.calls["/profile"])
+			}
+			for _, h := range headers {
+				if len(h) != 1 || h.Get("Accept") != "application/json" {
+					t.Fatalf("expected only original request headers but got %v", h)
+				}
+			}
+		})
+	}
+}
+
+func TestWithOverride(t *testing.T) {
+	var lock sync.Mutex
+	var starts []time.Time
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		lock.Lock()
+		starts = append(starts, time.Now())
+		lock.Unlock()
+		select {
+		case <-time.After(ms_50):
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	})
+	rs := NewResourceAverage(http.MethodGet, regexp.MustCompile(`profile`), time.Second, 1, http.StatusOK)
+	rt := NewRoundTripper(internal, 1, rs)
+	ctx := WithOverride(context.Background(), Override{Delay: ms_10, Calls: 2})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/profile", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	_ = resp.Body.Close()
+	lock.Lock()
+	defer lock.Unlock()
+	if len(starts) != 3 {
+		t.Fatalf("expected %d upstream calls but got %d", 3, len(starts))
+	}
+	// overridden delay governs when hedged calls are launched.
+	if d := starts[1].Sub(starts[0]); d < ms_10 || d > ms_50 {
+		t.Fatalf("expected hedged call to be launched after %v but got %v", ms_10, d)
+	}
+	// overridden requests still feed the resource latency learning.
+	stats, _ := GetStats(rt)
+	if d := stats.Resources[0].Delay; d < ms_50 || d >= time.Second {
+		t.Fatalf("expected learned resource delay around %v but got %v", ms_50, d)
+	}
+}
+
+func TestForceHedge(t *testing.T) {
+	ttable := map[string]struct {
+		ctx   context.Context
+		url   string
+		calls int
+		min   time.Duration
+		max   time.Duration
+	}{
+		"should launch hedged call after the delay without marker": {
+			ctx:   context.Background(),
+			url:   "http://example.com/profile",
+			calls: 2,
+			min:   ms_50,
+			max:   ms_100,
+		},
+		"should launch hedged call right away with marker": {
+			ctx:   ForceHedge(context.Background()),
+			url:   "http://example.com/profile",
+			calls: 2,
+			min:   0,
+			max:   ms_5,
+		},
+		"should not launch hedged call with marker for unmatched request": {
+			ctx:   ForceHedge(context.Background()),
+			url:   "http://example.com/users",
+			calls: 1,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			var lock sync.Mutex
+			var starts []time.Time
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				lock.Lock()
+				starts = append(starts, time.Now())
+				lock.Unlock()
+				select {
+				case <-time.After(ms_100):
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			})
+			rt := NewRoundTripper(internal, 1, NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_50, http.StatusOK))
+			req, _ := http.NewRequestWithContext(tcase.ctx, http.MethodGet, tcase.url, nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			lock.Lock()
+			defer lock.Unlock()
+			if len(starts) != tcase.calls {
+				t.Fatalf("expected %d upstream calls but got %d", tcase.calls, len(starts))
+			}
+			if len(starts) < 2 {
+				return
+			}
+			if d := starts[1].Sub(starts[0]); d < tcase.min || d > tcase.max {
+				t.Fatalf("expected hedged call to be launched within [%v, %v] but got %v", tcase.min, tcase.max, d)
+			}
+		})
+	}
+}
+
+func TestWithHedgeControl(t *testing.T) {
+	ttable := map[string]struct {
+		stop     time.Duration
+		calls    int
+		canceled int
+	}{
+		"should cancel outstanding hedged calls once stopped": {
+			stop:     ms_20,
+			calls:    3,
+			canceled: 2,
+		},
+		"should never launch hedged calls if stopped before the request": {
+			stop:  -1,
+			calls: 1,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			var lock sync.Mutex
+			var calls, canceled int
+			var done bool
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempt, _ := AttemptFromContext(req.Context())
+				lock.Lock()
+				calls++
+				lock.Unlock()
+				wait := ms_50
+				if attempt > 0 {
+					wait = time.Second
+				}
+				select {
+				case <-time.After(wait):
+					lock.Lock()
+					done = true
+					lock.Unlock()
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+				case <-req.Context().Done():
+					// only hedged calls canceled before the original call is done are counted.
+					lock.Lock()
+					if !done {
+						canceled++
+					}
+					lock.Unlock()
+					return nil, req.Context().Err()
+				}
+			})
+			rt := NewRoundTripper(internal, 2, NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK))
+			ctx, stop := WithHedgeControl(context.Background())
+			if tcase.stop < 0 {
+				stop()
+			} else {
+				time.AfterFunc(tcase.stop, stop)
+			}
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/profile", nil)
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			if elapsed := time.Since(start); elapsed > ms_100 {
+				t.Fatalf("expected original call to finish within %v but took %v", ms_100, elapsed)
+			}
+			if err := ctx.Err(); err != nil {
+				t.Fatalf("expected context to be left untouched but got %v", err)
+			}
+			// stopping the request after it's done has no effect.
+			stop()
+			lock.Lock()
+			defer lock.Unlock()
+			if calls != tcase.calls {
+				t.Fatalf("expected %d upstream calls but got %d", tcase.calls, calls)
+			}
+			if canceled != tcase.canceled {
+				t.Fatalf("expected %d canceled upstream calls but got %d", tcase.canceled, canceled)
+			}
+		})
+	}
+}
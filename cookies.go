@@ -0,0 +1,49 @@
+package hedgehog
+
+import "net/http"
+
+// WithMergeSetCookies enables merging of set cookie headers from successful losing http responses into the winner response,
+// so http client cookie jar processes cookies that were only set by losing responses.
+// Only losing responses that are already available once the winner is chosen are merged, e.g. ones collected
+// within divergence check grace window, so the winner is never delayed by merging.
+// Losing responses cookies are merged only if the winner has no cookie with the same name, domain and path,
+// so conflicting cookies keep the winner values.
+func WithMergeSetCookies() TransportOption {
+	return func(t *transport) {
+		t.mergeCookies = true
+	}
+}
+
+type cookieKey struct {
+	name   string
+	domain string
+	path   string
+}
+
+// mergeSetCookies appends provided loser response set cookie headers absent in provided winner response onto the latter.
+func mergeSetCookies(winner, loser *http.Response) {
+	lines := loser.Header.Values("Set-Cookie")
+	if len(lines) == 0 {
+		return
+	}
+	if winner.Header == nil {
+		winner.Header = make(http.Header)
+	}
+	seen := make(map[cookieKey]bool)
+	for _, c := range winner.Cookies() {
+		seen[cookieKey{name: c.Name, domain: c.Domain, path: c.Path}] = true
+	}
+	for _, line := range lines {
+		cookies := (&http.Response{Header: http.Header{"Set-Cookie": {line}}}).Cookies()
+		if len(cookies) != 1 {
+			continue
+		}
+		c := cookies[0]
+		key := cookieKey{name: c.Name, domain: c.Domain, path: c.Path}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		winner.Header.Add("Set-Cookie", line)
+	}
+}
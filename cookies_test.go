@@ -0,0 +1,76 @@
+package hedgehog
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMergeSetCookies(t *testing.T) {
+	ttable := map[string]struct {
+		cookies [][]string
+		jar     []string
+	}{
+		"should merge losing response cookies absent in winner response": {
+			cookies: [][]string{{"csrf=loser; Path=/"}, {"session=winner; Path=/"}},
+			jar:     []string{"csrf=loser", "session=winner"},
+		},
+		"should merge losing response cookies with duplicate name but different path": {
+			cookies: [][]string{{"session=loser; Path=/"}, {"session=winner; Path=/api"}},
+			jar:     []string{"session=loser"},
+		},
+		"should keep winner response cookies on conflict": {
+			cookies: [][]string{{"session=loser; Path=/", "csrf=loser; Path=/"}, {"session=winner; Path=/"}},
+			jar:     []string{"csrf=loser", "session=winner"},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var i int64
+			cookies := tcase.cookies
+			delays := []time.Duration{ms_20, ms_0}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				n := atomic.AddInt64(&i, 1) - 1
+				time.Sleep(delays[n])
+				for _, c := range cookies[n] {
+					w.Header().Add("Set-Cookie", c)
+				}
+			}))
+			defer srv.Close()
+			jar, _ := cookiejar.New(nil)
+			cli := &http.Client{
+				Jar: jar,
+				Transport: NewTransport(
+					http.DefaultTransport,
+					WithCalls(1),
+					WithMergeSetCookies(),
+					WithResources(NewResourceWithOptions(
+						NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_5, http.StatusOK),
+						ResourceWithDivergenceCheck(ms_50, nil, nil),
+					)),
+				),
+			}
+			resp, err := cli.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			u, _ := url.Parse(srv.URL)
+			var got []string
+			for _, c := range jar.Cookies(u) {
+				got = append(got, c.String())
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tcase.jar, got) {
+				t.Fatalf("expected jar cookies %v but got %v", tcase.jar, got)
+			}
+		})
+	}
+}
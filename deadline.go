@@ -0,0 +1,102 @@
+package hedgehog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrSoftDeadline defines hedged transport error that is returned once soft deadline fires
+// before any acceptable http response is received, it wraps `ErrAllAttemptsFailed` with errors occurred so far if any.
+type ErrSoftDeadline struct {
+	Deadline time.Duration
+	Err      error
+}
+
+func (err ErrSoftDeadline) Error() string {
+	if err.Err == nil {
+		return fmt.Sprintf("soft deadline %v exceeded: no response received", err.Deadline)
+	}
+	return fmt.Sprintf("soft deadline %v exceeded: %v", err.Deadline, err.Err)
+}
+
+func (err ErrSoftDeadline) Unwrap() error {
+	return err.Err
+}
+
+// ErrAttemptTimeout defines hedged transport error that is returned for single http call that didn't receive
+// response headers within attempt timeout, it matches `context.DeadlineExceeded` with `errors.Is`.
+type ErrAttemptTimeout struct {
+	Timeout time.Duration
+}
+
+func (err ErrAttemptTimeout) Error() string {
+	return fmt.Sprintf("hedged call attempt timeout %v exceeded: no response headers received", err.Timeout)
+}
+
+func (err ErrAttemptTimeout) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// WithAttemptTimeout sets hedged transport timeout for each original and hedged http call of matched requests,
+// each call that doesn't receive response headers within the timeout is canceled with `ErrAttemptTimeout`,
+// while other calls and request context deadline are not affected. Once response headers are received
+// the timeout no longer applies, so response body could be read for as long as needed.
+// Non positive timeout means no attempt timeout.
+func WithAttemptTimeout(timeout time.Duration) TransportOption {
+	return func(t *transport) {
+		t.timeout = timeout
+	}
+}
+
+// WithDeadlineMargin sets hedged transport safety margin for request context deadline, by default no hedged calls
+// are made for matched requests which context deadline is closer than the delay, as hedged calls couldn't be launched
+// before the deadline anyway. Provided margin is added to the delay, so hedged calls launched right before the deadline
+// that would just waste upstream capacity are not made either.
+func WithDeadlineMargin(margin time.Duration) TransportOption {
+	return func(t *transport) {
+		t.margin = margin
+	}
+}
+
+// WithSoftDeadline sets hedged transport soft deadline for matched requests, once it fires the transport stops waiting
+// for outstanding http calls and returns the best outcome available so far: successful response if any was received,
+// otherwise the best rejected response if `WithReturnRejected` is enabled, otherwise `ErrSoftDeadline` error.
+// Outstanding http calls are canceled and drained in background, request context deadline still applies independently.
+// Soft deadline could be overridden per call with `CallWithSoftDeadline`. Non positive deadline means no soft deadline.
+func WithSoftDeadline(deadline time.Duration) TransportOption {
+	return func(t *transport) {
+		t.deadline = deadline
+	}
+}
+
+// WithReturnRejected makes hedged transport return the best rejected http response, the one with the lowest status code
+// among responses that failed the resource check, instead of an error when no successful response is received.
+func WithReturnRejected() TransportOption {
+	return func(t *transport) {
+		t.rejected = true
+	}
+}
+
+// WithReturnRejectedRank makes hedged transport return the best rejected http response according to provided rank,
+// the one with the highest rank and then the earliest received, instead of an error when no successful response is received,
+// see `WithReturnRejected`. It lets callers get upstream structured error responses, e.g. 404 or 409 responses payloads.
+// Rejected responses that are not returned are drained and closed by the transport.
+func WithReturnRejectedRank(rank func(*http.Response) int) TransportOption {
+	return func(t *transport) {
+		t.rejected, t.rank = true, rank
+	}
+}
+
+// outranks returns whether provided rejected response is better than the current best one.
+func (t *transport) outranks(resp, best *http.Response) bool {
+	switch {
+	case best == nil:
+		return true
+	case t.rank != nil:
+		return t.rank(resp) > t.rank(best)
+	default:
+		return resp.StatusCode < best.StatusCode
+	}
+}
@@ -0,0 +1,309 @@
+package hedgehog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithSoftDeadline(t *testing.T) {
+	ttable := map[string]struct {
+		codes    []int
+		opts     []TransportOption
+		call     []CallOption
+		deadline time.Duration
+		code     int
+		err      error
+	}{
+		"should return successful response received before soft deadline": {
+			codes:    []int{http.StatusOK},
+			opts:     []TransportOption{WithSoftDeadline(ms_50)},
+			deadline: ms_50,
+			code:     http.StatusOK,
+		},
+		"should return rejected response once soft deadline fires": {
+			codes:    []int{http.StatusServiceUnavailable},
+			opts:     []TransportOption{WithSoftDeadline(ms_20), WithReturnRejected()},
+			deadline: ms_20,
+			code:     http.StatusServiceUnavailable,
+		},
+		"should return soft deadline error with rejected response without return rejected": {
+			codes:    []int{http.StatusServiceUnavailable},
+			opts:     []TransportOption{WithSoftDeadline(ms_20)},
+			deadline: ms_20,
+			err:      ErrResourceUnexpectedResponseCode{StatusCode: http.StatusServiceUnavailable},
+		},
+		"should return soft deadline error once soft deadline fires with nothing received": {
+			opts:     []TransportOption{WithReturnRejected()},
+			call:     []CallOption{CallWithSoftDeadline(ms_20)},
+			deadline: ms_20,
+			err:      ErrSoftDeadline{Deadline: ms_20},
+		},
+		"should return soft deadline error without hedged calls": {
+			opts:     []TransportOption{WithSoftDeadline(ms_20), WithCalls(0)},
+			deadline: ms_20,
+			err:      ErrSoftDeadline{Deadline: ms_20},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var calls int64
+			codes := tcase.codes
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				// the first calls respond right away with provided codes, the rest hang until canceled.
+				if n := atomic.AddInt64(&calls, 1) - 1; n < int64(len(codes)) {
+					return &http.Response{StatusCode: codes[n], Body: http.NoBody, Request: req}, nil
+				}
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			})
+			rt := NewTransport(internal, append(
+				[]TransportOption{
+					WithCalls(1),
+					WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_5, http.StatusOK)),
+				},
+				tcase.opts...,
+			)...)
+			ctx, cancel := context.WithTimeout(withCallOptions(context.Background(), tcase.call...), time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/search", nil)
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if elapsed := time.Since(start); elapsed > tcase.deadline+ms_50 {
+				t.Fatalf("expected round trip to return by soft deadline %v but took %v", tcase.deadline, elapsed)
+			}
+			if err == nil && resp.StatusCode != tcase.code {
+				t.Fatalf("expected response code %d but got %d", tcase.code, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestWithAttemptTimeout(t *testing.T) {
+	ttable := map[string]struct {
+		hang  []bool
+		max   time.Duration
+		err   error
+		calls int64
+	}{
+		"should time out hanging original call without affecting hedged call": {
+			hang:  []bool{true, false},
+			max:   ms_100,
+			calls: 2,
+		},
+		"should time out all hanging calls before request deadline": {
+			hang:  []bool{true, true},
+			max:   ms_100,
+			err:   ErrAttemptTimeout{Timeout: ms_50},
+			calls: 2,
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var calls int64
+			hang := tcase.hang
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if hang[atomic.AddInt64(&calls, 1)-1] {
+					<-req.Context().Done()
+					return
+				}
+				// the winner response body is streamed for longer than the attempt timeout.
+				w.(http.Flusher).Flush()
+				for i := 0; i < 4; i++ {
+					time.Sleep(ms_20)
+					_, _ = io.WriteString(w, "hedgehog")
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer srv.Close()
+			rt := NewTransport(
+				http.DefaultTransport,
+				WithCalls(1),
+				WithAttemptTimeout(ms_50),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_10, http.StatusOK)),
+			)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/search", nil)
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			if elapsed := time.Since(start); elapsed > tcase.max {
+				t.Fatalf("expected round trip to return within %v but took %v", tcase.max, elapsed)
+			}
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if err != nil {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("expected err to match deadline exceeded but got %v", err)
+				}
+				return
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			if err != nil || string(b) != strings.Repeat("hedgehog", 4) {
+				t.Fatalf("expected intact winner response body but got %q %v", string(b), err)
+			}
+			if c := atomic.LoadInt64(&calls); c != tcase.calls {
+				t.Fatalf("expected exactly %d http calls but got %d", tcase.calls, c)
+			}
+		})
+	}
+}
+
+func TestWithDeadlineMargin(t *testing.T) {
+	ttable := map[string]struct {
+		timeout time.Duration
+		delay   time.Duration
+		opts    []TransportOption
+		calls   int64
+	}{
+		"should make hedged calls with request deadline further than the delay": {
+			timeout: time.Second,
+			delay:   ms_10,
+			calls:   2,
+		},
+		"should not make hedged calls with request deadline closer than the delay": {
+			timeout: ms_50,
+			delay:   ms_100,
+			calls:   1,
+		},
+		"should not make hedged calls with request deadline closer than the delay with margin": {
+			timeout: ms_50,
+			delay:   ms_10,
+			opts:    []TransportOption{WithDeadlineMargin(ms_50)},
+			calls:   1,
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var calls int64
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt64(&calls, 1)
+				select {
+				case <-time.After(ms_20 + ms_10):
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			})
+			rt := NewTransport(internal, append(
+				tcase.opts,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), tcase.delay, http.StatusOK)),
+			)...)
+			ctx, cancel := context.WithTimeout(context.Background(), tcase.timeout)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/search", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			if c := atomic.LoadInt64(&calls); c != tcase.calls {
+				t.Fatalf("expected exactly %d http calls but got %d", tcase.calls, c)
+			}
+		})
+	}
+}
+
+type tclosed struct {
+	io.Reader
+	closed *int64
+}
+
+func (b tclosed) Close() error {
+	atomic.AddInt64(b.closed, 1)
+	return nil
+}
+
+func TestWithReturnRejectedRank(t *testing.T) {
+	ttable := map[string]struct {
+		codes []int
+		calls uint64
+		opts  []TransportOption
+		code  int
+		body  string
+	}{
+		"should return rejected response with body intact when all attempts are rejected": {
+			codes: []int{http.StatusNotFound, http.StatusNotFound},
+			calls: 1,
+			opts:  []TransportOption{WithReturnRejectedRank(nil)},
+			code:  http.StatusNotFound,
+			body:  "attempt 0",
+		},
+		"should return rejected response with lowest status code by default": {
+			codes: []int{http.StatusInternalServerError, http.StatusNotFound},
+			calls: 1,
+			opts:  []TransportOption{WithReturnRejectedRank(nil)},
+			code:  http.StatusNotFound,
+			body:  "attempt 1",
+		},
+		"should return rejected response with highest rank": {
+			codes: []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusConflict},
+			calls: 2,
+			opts: []TransportOption{WithReturnRejectedRank(func(resp *http.Response) int {
+				if resp.StatusCode == http.StatusConflict {
+					return 1
+				}
+				return 0
+			})},
+			code: http.StatusConflict,
+			body: "attempt 2",
+		},
+		"should return rejected response without hedged calls": {
+			codes: []int{http.StatusNotFound},
+			opts:  []TransportOption{WithReturnRejected()},
+			code:  http.StatusNotFound,
+			body:  "attempt 0",
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			var closed int64
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempt, _ := AttemptFromContext(req.Context())
+				// the attempts respond one after another, so all of them are made.
+				time.Sleep(time.Duration(attempt) * ms_5)
+				body := tclosed{Reader: strings.NewReader("attempt " + strconv.Itoa(attempt)), closed: &closed}
+				return &http.Response{StatusCode: tcase.codes[attempt], Body: body, Request: req}, nil
+			})
+			rt := NewTransport(internal, append(
+				tcase.opts,
+				WithCalls(tcase.calls),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK)),
+			)...)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			if resp.StatusCode != tcase.code {
+				t.Fatalf("expected response code %d but got %d", tcase.code, resp.StatusCode)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			if string(b) != tcase.body {
+				t.Fatalf("expected response body %q but got %q", tcase.body, string(b))
+			}
+			// rejected responses that are not returned are closed.
+			for i := 0; i < 100 && atomic.LoadInt64(&closed) < int64(tcase.calls); i++ {
+				time.Sleep(ms_1)
+			}
+			if c := atomic.LoadInt64(&closed); c != int64(tcase.calls) {
+				t.Fatalf("expected %d closed rejected responses but got %d", tcase.calls, c)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package hedgehog
+
+import (
+	"time"
+)
+
+// Delayer defines abstract hedged calls delay estimator that is capable of:
+// - returning delay which should be accounted before making hedged calls
+// - recording successful calls latencies to adjust the delay
+// Delayer makes resources delay policies reusable outside of http transport.
+type Delayer interface {
+	Delay() time.Duration
+	Record(time.Duration)
+}
+
+// recorder defines resource that is capable of recording successful calls latencies.
+type recorder interface {
+	delayer
+	record(time.Duration)
+}
+
+type delayerOf struct {
+	recorder
+}
+
+// NewDelayerStatic returns new delayer instance that always returns static specified delay.
+func NewDelayerStatic(delay time.Duration) Delayer {
+	return delayerOf{recorder: NewResourceStatic("", nil, delay).(static)}
+}
+
+// NewDelayerAverage returns new delayer instance that dynamically adjusts delay based on
+// recorded latencies average, see `NewResourceAverage` for details.
+func NewDelayerAverage(delay time.Duration, capacity int) Delayer {
+	return delayerOf{recorder: NewResourceAverage("", nil, delay, capacity).(*average)}
+}
+
+// NewDelayerPercentiles returns new delayer instance that dynamically adjusts delay based on
+// recorded latencies percentiles, see `NewResourcePercentiles` for details.
+func NewDelayerPercentiles(delay time.Duration, percentile float64, capacity int) Delayer {
+	return delayerOf{recorder: NewResourcePercentiles("", nil, delay, percentile, capacity).(*percentiles)}
+}
+
+func (d delayerOf) Delay() time.Duration {
+	return d.duration()
+}
+
+func (d delayerOf) Record(latency time.Duration) {
+	d.record(latency)
+}
+
+func (d delayerOf) learned() bool {
+	l, ok := d.recorder.(learner)
+	return ok && l.learned()
+}
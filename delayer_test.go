@@ -0,0 +1,45 @@
+package hedgehog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayers(t *testing.T) {
+	table := map[string]struct {
+		delayer Delayer
+		records []time.Duration
+		delay   time.Duration
+	}{
+		"static delayer should ignore records": {
+			delayer: NewDelayerStatic(ms_10),
+			records: []time.Duration{ms_1, ms_5},
+			delay:   ms_10,
+		},
+		"average delayer should use records average once capacity is reached": {
+			delayer: NewDelayerAverage(ms_10, 2),
+			records: []time.Duration{ms_1, ms_5},
+			delay:   3 * time.Millisecond,
+		},
+		"percentiles delayer should use records percentile once half of capacity is reached": {
+			delayer: NewDelayerPercentiles(ms_10, 0.5, 4),
+			records: []time.Duration{ms_5, ms_1},
+			delay:   ms_1,
+		},
+		"percentiles delayer should use initial delay before half of capacity is reached": {
+			delayer: NewDelayerPercentiles(ms_10, 0.5, 6),
+			records: []time.Duration{ms_5, ms_1},
+			delay:   ms_10,
+		},
+	}
+	for tname, tcase := range table {
+		t.Run(tname, func(t *testing.T) {
+			for _, d := range tcase.records {
+				tcase.delayer.Record(d)
+			}
+			if delay := tcase.delayer.Delay(); delay != tcase.delay {
+				t.Fatalf("expected delay %v but got %v", tcase.delay, delay)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package hedgehog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+)
+
+// divergenceBodyLimit defines max response body prefix size that is hashed and compared by divergence check.
+const divergenceBodyLimit = 64 << 10
+
+// DivergenceAttempt defines single completed http call metadata reported by divergence check.
+type DivergenceAttempt struct {
+	Attempt    int
+	StatusCode int
+	ETag       string
+	BodyHash   string
+}
+
+// DivergenceReport defines divergence check report that lists hedged calls responses disagreeing with the winner,
+// request is identified by its method and label produced by hedged transport label sanitizer.
+type DivergenceReport struct {
+	Method   string
+	Label    string
+	Winner   DivergenceAttempt
+	Diverged []DivergenceAttempt
+}
+
+type divergence struct {
+	grace        time.Duration
+	compare      func(a, b *http.Response) bool
+	onDivergence func(DivergenceReport)
+}
+
+// ResourceWithDivergenceCheck enables verification of completed hedged calls responses for the resource.
+// After the first successful response the transport keeps collecting other successful responses within provided grace window,
+// then compares each of them with the winner using provided compare function and
+// invokes provided callback with the report if any of them disagree; the returned winner is unaffected.
+// Response bodies passed to compare function contain only up to the first 64KiB of the body.
+// If nil compare function is provided, responses are compared by status code, `ETag` header and body.
+func ResourceWithDivergenceCheck(grace time.Duration, compare func(a, b *http.Response) bool, onDivergence func(DivergenceReport)) ResourceOption {
+	if compare == nil {
+		compare = compareResponses
+	}
+	return func(r *decorated) {
+		r.divergence = &divergence{grace: grace, compare: compare, onDivergence: onDivergence}
+	}
+}
+
+func divergenceOf(rs Resource) *divergence {
+	if d, ok := rs.(*decorated); ok {
+		return d.divergence
+	}
+	return nil
+}
+
+// peek reads response body prefix and replaces response body with replayable one.
+func (dv *divergence) peek(resp *http.Response) []byte {
+	prefix, _ := io.ReadAll(io.LimitReader(resp.Body, divergenceBodyLimit))
+	resp.Body = replayBody{Reader: io.MultiReader(bytes.NewReader(prefix), resp.Body), Closer: resp.Body}
+	return prefix
+}
+
+// verify compares the winner with the rest of successful responses and closes the latter.
+func (dv *divergence) verify(method, label string, results []result) {
+	if len(results) < 2 {
+		return
+	}
+	winner := results[0]
+	report := DivergenceReport{Method: method, Label: label, Winner: dv.attempt(winner)}
+	for _, r := range results[1:] {
+		_ = r.resp.Body.Close()
+		if !dv.compare(dv.snapshot(winner), dv.snapshot(r)) {
+			report.Diverged = append(report.Diverged, dv.attempt(r))
+		}
+	}
+	if len(report.Diverged) > 0 && dv.onDivergence != nil {
+		dv.onDivergence(report)
+	}
+}
+
+func (dv *divergence) snapshot(r result) *http.Response {
+	resp := *r.resp
+	resp.Body = io.NopCloser(bytes.NewReader(r.prefix))
+	return &resp
+}
+
+func (dv *divergence) attempt(r result) DivergenceAttempt {
+	h := sha256.Sum256(r.prefix)
+	return DivergenceAttempt{
+		Attempt:    int(r.attempt),
+		StatusCode: r.resp.StatusCode,
+		ETag:       r.resp.Header.Get("ETag"),
+		BodyHash:   hex.EncodeToString(h[:]),
+	}
+}
+
+func compareResponses(a, b *http.Response) bool {
+	if a.StatusCode != b.StatusCode || a.Header.Get("ETag") != b.Header.Get("ETag") {
+		return false
+	}
+	ab, _ := io.ReadAll(a.Body)
+	bb, _ := io.ReadAll(b.Body)
+	return bytes.Equal(ab, bb)
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
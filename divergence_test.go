@@ -0,0 +1,112 @@
+package hedgehog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func tdivserv(bodies []string, etags []string, delays []time.Duration) (string, context.CancelFunc) {
+	var i int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt64(&i, 1) - 1
+		time.Sleep(delays[n])
+		if etags != nil {
+			w.Header().Set("ETag", etags[n])
+		}
+		_, _ = io.WriteString(w, bodies[n])
+	}))
+	return srv.URL, srv.Close
+}
+
+func thash(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestResourceWithDivergenceCheck(t *testing.T) {
+	ttable := map[string]struct {
+		bodies  []string
+		etags   []string
+		delays  []time.Duration
+		compare func(a, b *http.Response) bool
+		body    string
+		report  *DivergenceReport
+	}{
+		"should not report on equal responses": {
+			bodies: []string{"alpha", "alpha"},
+			delays: []time.Duration{ms_20, ms_0},
+			body:   "alpha",
+		},
+		"should report on different response bodies": {
+			bodies: []string{"alpha", "beta"},
+			delays: []time.Duration{ms_20, ms_0},
+			body:   "beta",
+			report: &DivergenceReport{
+				Method:   http.MethodGet,
+				Label:    "search",
+				Winner:   DivergenceAttempt{Attempt: 1, StatusCode: http.StatusOK, BodyHash: thash("beta")},
+				Diverged: []DivergenceAttempt{{Attempt: 0, StatusCode: http.StatusOK, BodyHash: thash("alpha")}},
+			},
+		},
+		"should report on different response etags": {
+			bodies: []string{"alpha", "alpha"},
+			etags:  []string{"v1", "v2"},
+			delays: []time.Duration{ms_20, ms_0},
+			body:   "alpha",
+			report: &DivergenceReport{
+				Method:   http.MethodGet,
+				Label:    "search",
+				Winner:   DivergenceAttempt{Attempt: 1, StatusCode: http.StatusOK, ETag: "v2", BodyHash: thash("alpha")},
+				Diverged: []DivergenceAttempt{{Attempt: 0, StatusCode: http.StatusOK, ETag: "v1", BodyHash: thash("alpha")}},
+			},
+		},
+		"should not report on responses outside of grace window": {
+			bodies: []string{"alpha", "beta"},
+			delays: []time.Duration{ms_100, ms_0},
+			body:   "beta",
+		},
+		"should use provided compare function": {
+			bodies: []string{"alpha", "beta"},
+			delays: []time.Duration{ms_20, ms_0},
+			body:   "beta",
+			compare: func(a, b *http.Response) bool {
+				return a.StatusCode == b.StatusCode
+			},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var report *DivergenceReport
+			uri, stop := tdivserv(tcase.bodies, tcase.etags, tcase.delays)
+			defer stop()
+			cli := NewHTTPClient(&http.Client{}, ClientWithCalls(1), ClientWithResources(NewResourceWithOptions(
+				NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_5, http.StatusOK),
+				ResourceWithName("search"),
+				ResourceWithDivergenceCheck(ms_50, tcase.compare, func(r DivergenceReport) {
+					report = &r
+				}),
+			)))
+			resp, err := cli.Get(uri)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if string(b) != tcase.body {
+				t.Fatalf("expected response body %q but got %q", tcase.body, string(b))
+			}
+			if !reflect.DeepEqual(tcase.report, report) {
+				t.Fatalf("expected divergence report %+v but got %+v", tcase.report, report)
+			}
+		})
+	}
+}
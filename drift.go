@@ -0,0 +1,77 @@
+package hedgehog
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// driftSmoothing defines drift baseline exponential smoothing factor.
+const driftSmoothing = 0.1
+
+// DriftEvent defines resource delay drift alert event.
+type DriftEvent struct {
+	Resource string
+	Old      time.Duration
+	New      time.Duration
+	Change   float64
+	Samples  uint64
+}
+
+type drift struct {
+	threshold   float64
+	minInterval time.Duration
+	fn          func(DriftEvent)
+	now         func() time.Time
+	lock        sync.Mutex
+	baseline    float64
+	samples     uint64
+	last        time.Time
+}
+
+// ResourceWithDelayDriftAlert enables resource delay drift alerting.
+// After each resource hook update the newly computed resource delay is compared against its smoothed baseline,
+// when their relative change exceeds provided threshold provided callback is invoked with drift event.
+// Callback invocations are rate limited by provided min interval, they are made asynchronously and
+// any callback panic is recovered.
+// Drift alerting is only supported for resources created by this package.
+func ResourceWithDelayDriftAlert(threshold float64, minInterval time.Duration, fn func(DriftEvent)) ResourceOption {
+	return func(r *decorated) {
+		r.drift = &drift{threshold: threshold, minInterval: minInterval, fn: fn, now: time.Now}
+	}
+}
+
+func (d *drift) update(name string, delay time.Duration) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.samples++
+	val := float64(delay)
+	if d.samples == 1 || d.baseline == 0 {
+		d.baseline = val
+		return
+	}
+	old := d.baseline
+	d.baseline = old*(1.0-driftSmoothing) + val*driftSmoothing
+	change := math.Abs(val-old) / old
+	if change <= d.threshold {
+		return
+	}
+	now := d.now()
+	if !d.last.IsZero() && now.Sub(d.last) < d.minInterval {
+		return
+	}
+	d.last = now
+	event := DriftEvent{
+		Resource: name,
+		Old:      time.Duration(old),
+		New:      delay,
+		Change:   change,
+		Samples:  d.samples,
+	}
+	go func() {
+		defer func() {
+			_ = recover()
+		}()
+		d.fn(event)
+	}()
+}
@@ -0,0 +1,100 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+type tdelays struct {
+	static
+	lock   sync.Mutex
+	delays []time.Duration
+	cur    time.Duration
+}
+
+func (r *tdelays) duration() time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.cur
+}
+
+func (r *tdelays) Hook(*http.Request) func(*http.Response) {
+	return func(*http.Response) {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.cur, r.delays = r.delays[0], r.delays[1:]
+	}
+}
+
+func TestResourceWithDelayDriftAlert(t *testing.T) {
+	ttable := map[string]struct {
+		delays      []time.Duration
+		threshold   float64
+		minInterval time.Duration
+		events      []DriftEvent
+	}{
+		"should not fire on stable delays": {
+			delays:      []time.Duration{ms_10, ms_10, ms_10, ms_10, ms_10, ms_10},
+			threshold:   0.5,
+			minInterval: time.Hour,
+		},
+		"should fire once on step change": {
+			delays:      []time.Duration{ms_10, ms_10, ms_10, ms_10, ms_50, ms_50, ms_50, ms_50},
+			threshold:   0.5,
+			minInterval: time.Hour,
+			events:      []DriftEvent{{Resource: "search", Old: ms_10, New: ms_50, Change: 4, Samples: 5}},
+		},
+		"should not fire on change within threshold": {
+			delays:      []time.Duration{ms_10, ms_10, ms_10, ms_10, ms_10 * 14 / 10},
+			threshold:   0.5,
+			minInterval: time.Hour,
+		},
+		"should fire each time without min interval": {
+			delays:      []time.Duration{ms_10, ms_50, ms_1},
+			threshold:   0.5,
+			minInterval: 0,
+			events: []DriftEvent{
+				{Resource: "search", Old: ms_10, New: ms_50, Change: 4, Samples: 2},
+				{Resource: "search", Old: ms_10 * 14 / 10, New: ms_1, Change: 13.0 / 14.0, Samples: 3},
+			},
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			t.Parallel()
+			var lock sync.Mutex
+			var events []DriftEvent
+			rs := NewResourceWithOptions(
+				&tdelays{static: NewResourceStatic("", regexp.MustCompile(``), ms_1, 0).(static), delays: tcase.delays},
+				ResourceWithName("search"),
+				ResourceWithDelayDriftAlert(tcase.threshold, tcase.minInterval, func(e DriftEvent) {
+					lock.Lock()
+					events = append(events, e)
+					lock.Unlock()
+					panic("callbacks panics should be recovered")
+				}),
+			)
+			for range tcase.delays {
+				rs.Hook(&http.Request{})(&http.Response{})
+				// let async callbacks be delivered in order.
+				time.Sleep(ms_1)
+			}
+			time.Sleep(ms_20)
+			lock.Lock()
+			defer lock.Unlock()
+			if len(events) != len(tcase.events) {
+				t.Fatalf("expected %d drift events but got %+v", len(tcase.events), events)
+			}
+			for i, e := range tcase.events {
+				if e.Resource != events[i].Resource || e.Old != events[i].Old || e.New != events[i].New ||
+					e.Samples != events[i].Samples || e.Change-events[i].Change > 1e-9 || events[i].Change-e.Change > 1e-9 {
+					t.Fatalf("expected drift event %+v but got %+v", e, events[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package hedgehog
+
+import (
+	"net/http"
+	"time"
+)
+
+// DryRunDecision defines hedged transport dry run decision for single matched request,
+// delay is only reported for resources created by this package, elapsed is original call latency,
+// hedged reports whether hedged calls would have been launched as original call took longer than the delay.
+type DryRunDecision struct {
+	Request  *http.Request
+	Resource Resource
+	Calls    int
+	Delay    time.Duration
+	Elapsed  time.Duration
+	Hedged   bool
+	Err      error
+}
+
+// WithDryRun makes hedged transport run in dry run mode, so hedging effect could be estimated without any extra http calls.
+// In dry run mode matched requests original calls are made exactly as by underlying transport alone and their responses
+// and errors are always returned as is, no hedged calls are ever made, instead provided report function is called
+// with the decision the transport would have made for each matched request. Original calls responses still feed
+// the resource hooks, so the resource latency learning is warm once dry run mode is turned off.
+func WithDryRun(report func(DryRunDecision)) TransportOption {
+	return func(t *transport) {
+		t.dryRun = report
+	}
+}
+
+// dryRoundTrip makes original http call for the matched request as is and reports would be hedging decision.
+func (t *transport) dryRoundTrip(req *http.Request, rs Resource, calls uint64, o override) (*http.Response, error) {
+	calls = callsOf(rs, calls)
+	if o.calls > 0 {
+		calls = o.calls
+	}
+	d := DryRunDecision{Request: req, Resource: rs, Calls: int(calls), Delay: o.delay}
+	after := rs.After()
+	if o.delay > 0 {
+		after = time.After(o.delay)
+	} else if dl, ok := rs.(delayer); ok {
+		d.Delay = dl.duration()
+	}
+	h := rs.Hook(req)
+	start := time.Now()
+	resp, err := t.internal.RoundTrip(req)
+	d.Elapsed, d.Err = time.Since(start), err
+	select {
+	case <-after:
+		d.Hedged = calls > 0
+	default:
+	}
+	if err == nil && t.check(rs, req, resp) == nil {
+		h(resp)
+	}
+	t.dryRun(d)
+	return resp, err
+}
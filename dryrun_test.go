@@ -0,0 +1,51 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithDryRun(t *testing.T) {
+	var calls int64
+	var latency time.Duration
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt64(&calls, 1)
+		time.Sleep(latency)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	var decisions []DryRunDecision
+	rt := NewTransport(
+		internal,
+		WithCalls(2),
+		WithResources(NewResourceAverage(http.MethodGet, regexp.MustCompile(`profile`), ms_20, 1, http.StatusOK)),
+		WithDryRun(func(d DryRunDecision) {
+			decisions = append(decisions, d)
+		}),
+	)
+	for _, l := range []time.Duration{ms_1, ms_50} {
+		latency = l
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+	if c := atomic.LoadInt64(&calls); c != 2 {
+		t.Fatalf("expected exactly %d http calls but got %d", 2, c)
+	}
+	if len(decisions) != 2 {
+		t.Fatalf("expected %d dry run decisions but got %d", 2, len(decisions))
+	}
+	fast, slow := decisions[0], decisions[1]
+	if fast.Hedged || fast.Calls != 2 || fast.Delay != ms_20 || fast.Elapsed >= ms_20 {
+		t.Fatalf("expected fast original call not to be hedged but got %+v", fast)
+	}
+	// the resource latency learning still occurs in dry run mode.
+	if !slow.Hedged || slow.Calls != 2 || slow.Delay >= ms_20 || slow.Elapsed < ms_50 {
+		t.Fatalf("expected slow original call to be hedged after learned delay but got %+v", slow)
+	}
+}
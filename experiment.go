@@ -0,0 +1,110 @@
+package hedgehog
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// experimentWindow defines max number of latest latencies per cohort used to calculate cohort quantiles.
+const experimentWindow = 1024
+
+// Cohort defines hedged transport A/B experiment cohort of the request, see `WithExperiment`.
+type Cohort int
+
+// Hedged transport A/B experiment cohorts, requests of transport without experiment have no cohort.
+const (
+	CohortNone Cohort = iota
+	CohortTreatment
+	CohortControl
+)
+
+// String returns cohort name.
+func (c Cohort) String() string {
+	switch c {
+	case CohortTreatment:
+		return "treatment"
+	case CohortControl:
+		return "control"
+	default:
+		return "none"
+	}
+}
+
+type experiment struct {
+	fraction  float64
+	lock      sync.Mutex
+	rnd       Rand
+	requests  [2]uint64
+	latencies [2][]time.Duration
+}
+
+// WithExperiment enables hedged transport A/B experiment mode.
+// In this mode each matched request is randomly assigned either to control cohort with provided probability
+// or to treatment cohort otherwise; requests from control cohort never make hedged calls,
+// while still running resource checks and hooks exactly as requests from treatment cohort.
+// Cohort assignment is driven by random source created from provided seed, so it's reproducible.
+// Running latency quantiles per cohort are exposed via `GetStats`, while observers events are tagged with the request cohort.
+func WithExperiment(controlFraction float64, seed int64) TransportOption {
+	controlFraction = math.Max(math.Min(controlFraction, 1.0), 0.0)
+	return func(t *transport) {
+		t.experiment = &experiment{
+			fraction: controlFraction,
+			rnd:      newLockedRand(seed),
+		}
+	}
+}
+
+// assign assigns the request to random cohort, nil experiment assigns no cohort.
+func (e *experiment) assign() Cohort {
+	if e == nil {
+		return CohortNone
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.rnd.Float64() < e.fraction {
+		return CohortControl
+	}
+	return CohortTreatment
+}
+
+func (e *experiment) record(c Cohort, start time.Time) {
+	d := time.Since(start)
+	i := c - CohortTreatment
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.requests[i]++
+	e.latencies[i] = append(e.latencies[i], d)
+	// in case of overflow: just drop the oldest latency.
+	if len(e.latencies[i]) > experimentWindow {
+		e.latencies[i] = e.latencies[i][1:]
+	}
+}
+
+func (e *experiment) stats() ExperimentStats {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return ExperimentStats{
+		ControlFraction: e.fraction,
+		Treatment:       cohortStats(e.requests[0], e.latencies[0]),
+		Control:         cohortStats(e.requests[1], e.latencies[1]),
+	}
+}
+
+func cohortStats(requests uint64, latencies []time.Duration) CohortStats {
+	stats := CohortStats{Requests: requests}
+	if len(latencies) == 0 {
+		return stats
+	}
+	lat := make([]time.Duration, len(latencies))
+	copy(lat, latencies)
+	sort.Slice(lat, func(i, j int) bool {
+		return lat[i] < lat[j]
+	})
+	quantile := func(q float64) time.Duration {
+		return lat[int(math.Ceil(float64(len(lat))*q))-1]
+	}
+	stats.P50, stats.P90, stats.P99 = quantile(0.5), quantile(0.9), quantile(0.99)
+	return stats
+}
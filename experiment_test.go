@@ -0,0 +1,165 @@
+package hedgehog
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/1pkg/hedgehog/hedgehogtest"
+)
+
+func TestWithExperiment(t *testing.T) {
+	const requests = 500
+	ttable := map[string]struct {
+		fraction float64
+		seed     int64
+		code     int
+		control  float64
+		err      error
+	}{
+		"should never assign requests to control cohort with zero fraction": {
+			fraction: 0,
+			seed:     1,
+			code:     http.StatusOK,
+			control:  0,
+		},
+		"should assign requests to control cohort proportionally": {
+			fraction: 0.3,
+			seed:     42,
+			code:     http.StatusOK,
+			control:  0.3,
+		},
+		"should assign all requests to control cohort with full fraction": {
+			fraction: 1.2,
+			seed:     7,
+			code:     http.StatusOK,
+			control:  1,
+		},
+		"should still check control cohort requests": {
+			fraction: 1,
+			seed:     7,
+			code:     http.StatusCreated,
+			control:  1,
+			err:      ErrResourceUnexpectedResponseCode{StatusCode: http.StatusOK},
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			t.Parallel()
+			// upstream is slower than resource delay so hedged calls are always made for treatment cohort.
+			rec := newRecorder(ms_1)
+			rt := NewTransport(
+				rec,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_0, tcase.code)),
+				WithExperiment(tcase.fraction, tcase.seed),
+			)
+			for i := 0; i < requests; i++ {
+				req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+				if _, err := rt.RoundTrip(req); !errors.Is(err, tcase.err) {
+					t.Fatalf("expected err %v but got %v", tcase.err, err)
+				}
+			}
+			stats, _ := GetStats(rt)
+			control, treatment := stats.Experiment.Control.Requests, stats.Experiment.Treatment.Requests
+			if control+treatment != requests {
+				t.Fatalf("expected %d experiment requests but got %d", requests, control+treatment)
+			}
+			if ratio := float64(control) / requests; math.Abs(ratio-tcase.control) > 0.05 {
+				t.Fatalf("expected control cohort ratio ~%.2f but got %.2f", tcase.control, ratio)
+			}
+			// control cohort requests never produce hedged calls.
+			if expected := int(control + treatment*2); rec.calls["/"] != expected {
+				t.Fatalf("expected %d upstream calls but got %d", expected, rec.calls["/"])
+			}
+			if control > 0 && stats.Experiment.Control.P99 < stats.Experiment.Control.P50 {
+				t.Fatalf("expected control cohort quantiles be ordered but got %+v", stats.Experiment.Control)
+			}
+			// the same seed produces the same cohort assignment.
+			replay := NewTransport(rec, WithResources(NewResourceStatic(http.MethodGet, nil, ms_0, tcase.code)), WithExperiment(tcase.fraction, tcase.seed))
+			for i := 0; i < requests; i++ {
+				req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+				_, _ = replay.RoundTrip(req)
+			}
+			if rstats, _ := GetStats(replay); rstats.Experiment.Control.Requests != control {
+				t.Fatalf("expected replayed control cohort %d but got %d", control, rstats.Experiment.Control.Requests)
+			}
+		})
+	}
+}
+
+func TestWithExperimentRandSource(t *testing.T) {
+	rec := newRecorder(ms_1)
+	rt := NewTransport(
+		rec,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_0, http.StatusOK)),
+		WithExperiment(0.5, 1),
+		WithRandSource(hedgehogtest.NewSequence(0.2, 0.8, 0.6, 0.3)),
+	)
+	calls := make([]int, 0, 4)
+	for i := 0; i < 4; i++ {
+		prev := rec.calls["/"]
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+		calls = append(calls, rec.calls["/"]-prev)
+	}
+	// control cohort requests never produce hedged calls.
+	if expected := []int{1, 2, 2, 1}; !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected upstream calls %v but got %v", expected, calls)
+	}
+}
+
+func TestWithExperimentEventsCohort(t *testing.T) {
+	var lock sync.Mutex
+	cohorts := make(map[EventKind][]Cohort)
+	obs := ObserverFunc(func(e Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		cohorts[e.Kind] = append(cohorts[e.Kind], e.Cohort)
+	})
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		time.Sleep(ms_10)
+		code := http.StatusOK
+		if req.URL.Path == "/fail" {
+			code = http.StatusInternalServerError
+		}
+		return &http.Response{StatusCode: code, Body: http.NoBody, Request: req}, nil
+	})
+	rt := NewTransport(
+		internal,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_1, http.StatusOK)),
+		WithExperiment(0.5, 1),
+		WithRandSource(hedgehogtest.NewSequence(0.2, 0.8, 0.2, 0.8)),
+		WithTransportObserver(obs),
+	)
+	for _, path := range []string{"/", "/", "/fail", "/fail"} {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		resp, err := rt.RoundTrip(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	expected := map[EventKind][]Cohort{
+		EventMatch:        {CohortControl, CohortTreatment, CohortControl, CohortTreatment},
+		EventWinner:       {CohortControl, CohortTreatment},
+		EventFailure:      {CohortControl, CohortTreatment},
+		EventAttemptStart: {CohortControl, CohortTreatment, CohortTreatment, CohortControl, CohortTreatment, CohortTreatment},
+	}
+	for kind, exp := range expected {
+		if !reflect.DeepEqual(cohorts[kind], exp) {
+			t.Fatalf("expected %v events cohorts %v but got %v", kind, exp, cohorts[kind])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package hedgehog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type failoverKey struct{}
+
+type failover struct {
+	target     *url.URL
+	classifier func(error) bool
+}
+
+// WithFailover enables single sequential failover http call to provided target base url,
+// that is made once original and all hedged calls of a matched request failed.
+// Failover call is only made if the hedged exchange error passes provided classifier and the request context is not done yet,
+// by default failover is made on transport errors and on unexpected 5xx response codes but not on any other response codes.
+// Failover call uses the request with scheme and host replaced by the target ones and path prefixed by the target path,
+// its response could be recognized by `IsFailover`. If failover call fails too the hedged exchange error is returned.
+func WithFailover(target *url.URL, classifier func(error) bool) TransportOption {
+	return func(t *transport) {
+		if classifier == nil {
+			classifier = failoverable
+		}
+		t.failover = &failover{target: target, classifier: classifier}
+	}
+}
+
+// IsFailover returns whether provided response is the result of failover http call.
+func IsFailover(resp *http.Response) bool {
+	if resp == nil || resp.Request == nil {
+		return false
+	}
+	ok, _ := resp.Request.Context().Value(failoverKey{}).(bool)
+	return ok
+}
+
+// failoverable is the default failover classifier.
+func failoverable(err error) bool {
+	var code ErrResourceUnexpectedResponseCode
+	if errors.As(err, &code) {
+		return code.StatusCode >= http.StatusInternalServerError
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// roundTrip makes failover http call for provided request if provided hedged exchange error allows it
+// and returns its response if it succeeded, nil failover never makes any calls.
+func (f *failover) roundTrip(t *transport, req *http.Request, rs Resource, attempt int, err error, obs observers) (*http.Response, bool) {
+	if f == nil || err == nil || req.Context().Err() != nil || !f.classifier(err) {
+		return nil, false
+	}
+	ctx := context.WithValue(context.WithValue(req.Context(), failoverKey{}, true), attemptKey{}, attempt)
+	freq := req.Clone(ctx)
+	u := *req.URL
+	u.Scheme, u.Host = f.target.Scheme, f.target.Host
+	u.Path, u.RawPath = strings.TrimSuffix(f.target.Path, "/")+req.URL.Path, ""
+	freq.URL, freq.Host = &u, ""
+	obs.emit(Event{Kind: EventAttemptStart, Request: freq, Resource: rs, Attempt: attempt, Failover: true})
+	start := time.Now()
+	err = replay(freq)
+	if err == nil {
+		err = t.authorize(freq)
+	}
+	if err == nil {
+		t.modify(freq, attempt)
+	}
+	var resp *http.Response
+	if err == nil {
+		resp, err = t.internal.RoundTrip(freq)
+	}
+	if err == nil {
+		if err = t.check(rs, freq, resp); err != nil {
+			t.discard(resp)
+		}
+	}
+	if obs.enabled() {
+		e := Event{Kind: EventAttemptEnd, Request: freq, Resource: rs, Attempt: attempt, Elapsed: time.Since(start), Err: err, Failover: true}
+		if resp != nil {
+			e.StatusCode = resp.StatusCode
+		}
+		obs.emit(e)
+	}
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
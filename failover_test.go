@@ -0,0 +1,86 @@
+package hedgehog
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithFailover(t *testing.T) {
+	ttable := map[string]struct {
+		primary  int
+		backup   int
+		calls    int64
+		body     string
+		err      error
+		failover bool
+	}{
+		"should return failover response after all attempts fail": {
+			primary:  http.StatusServiceUnavailable,
+			backup:   http.StatusOK,
+			calls:    1,
+			body:     "/v1/search",
+			failover: true,
+		},
+		"should return hedged exchange error after failover fails too": {
+			primary: http.StatusServiceUnavailable,
+			backup:  http.StatusBadGateway,
+			calls:   1,
+			err:     ErrResourceUnexpectedResponseCode{StatusCode: http.StatusServiceUnavailable},
+		},
+		"should not failover on not found response code": {
+			primary: http.StatusNotFound,
+			backup:  http.StatusOK,
+			err:     ErrResourceUnexpectedResponseCode{StatusCode: http.StatusNotFound},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var calls int64
+			primary, code := tcase.primary, tcase.backup
+			backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt64(&calls, 1)
+				w.WriteHeader(code)
+				_, _ = io.WriteString(w, req.URL.Path)
+			}))
+			defer backup.Close()
+			target, _ := url.Parse(backup.URL + "/v1/")
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if req.URL.Host == target.Host {
+					return http.DefaultTransport.RoundTrip(req)
+				}
+				return &http.Response{StatusCode: primary, Body: http.NoBody, Request: req}, nil
+			})
+			rt := NewTransport(
+				internal,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK)),
+				WithFailover(target, nil),
+			)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			resp, err := rt.RoundTrip(req)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if c := atomic.LoadInt64(&calls); c != tcase.calls {
+				t.Fatalf("expected %d failover calls but got %d", tcase.calls, c)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			if string(b) != tcase.body {
+				t.Fatalf("expected response body %q but got %q", tcase.body, string(b))
+			}
+			if IsFailover(resp) != tcase.failover {
+				t.Fatalf("expected failover response %v but got %v", tcase.failover, IsFailover(resp))
+			}
+		})
+	}
+}
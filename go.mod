@@ -1,5 +1,3 @@
 module github.com/1pkg/hedgehog
 
-go 1.16
-
-require golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+go 1.18
@@ -0,0 +1,114 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// Matcher defines abstract http request matcher.
+type Matcher interface {
+	Match(*http.Request) bool
+}
+
+// MatcherFunc defines functional matcher adapter.
+type MatcherFunc func(*http.Request) bool
+
+// Match calls the function with provided request.
+func (f MatcherFunc) Match(req *http.Request) bool {
+	return f(req)
+}
+
+// Checker defines abstract http response checker.
+type Checker interface {
+	Check(*http.Response) error
+}
+
+// CheckerFunc defines functional checker adapter.
+type CheckerFunc func(*http.Response) error
+
+// Check calls the function with provided response.
+func (f CheckerFunc) Check(resp *http.Response) error {
+	return f(resp)
+}
+
+// NewMatcher returns new matcher instance that matches each request against both provided http method and full url regexp.
+func NewMatcher(method string, url *regexp.Regexp) Matcher {
+	return NewResourceStatic(method, url, 0)
+}
+
+// NewChecker returns new checker instance that checks if response result http code is included in provided allowed codes,
+// if it is not it returnes `ErrResourceUnexpectedResponseCode`.
+func NewChecker(allowedCodes ...int) Checker {
+	return NewResourceStatic("", nil, 0, allowedCodes...)
+}
+
+type group struct {
+	delayer  Delayer
+	checker  Checker
+	matchers []Matcher
+	hits     []uint64
+}
+
+// NewResourceGroup returns new resource instance that matches requests by any of provided matchers,
+// while all of them share provided delayer, so delay is estimated over combined successful responses latencies.
+// Returned resource checks responses with provided checker, if nil checker is provided any response is accepted.
+// If nil delayer is provided hedged calls are made without delay.
+// Returned resource counts each matcher hits separately, the first matching matcher is accounted.
+func NewResourceGroup(delayer Delayer, checker Checker, matchers ...Matcher) Resource {
+	if delayer == nil {
+		delayer = NewDelayerStatic(0)
+	}
+	return &group{
+		delayer:  delayer,
+		checker:  checker,
+		matchers: matchers,
+		hits:     make([]uint64, len(matchers)),
+	}
+}
+
+func (r *group) After() <-chan time.Time {
+	return time.After(r.duration())
+}
+
+func (r *group) duration() time.Duration {
+	return r.delayer.Delay()
+}
+
+func (r *group) learned() bool {
+	l, ok := r.delayer.(learner)
+	return ok && l.learned()
+}
+
+func (r *group) Match(req *http.Request) bool {
+	for i, m := range r.matchers {
+		if m.Match(req) {
+			atomic.AddUint64(&r.hits[i], 1)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *group) Check(resp *http.Response) error {
+	if r.checker == nil {
+		return nil
+	}
+	return r.checker.Check(resp)
+}
+
+func (r *group) Hook(*http.Request) func(*http.Response) {
+	t := time.Now()
+	return func(*http.Response) {
+		r.delayer.Record(time.Since(t))
+	}
+}
+
+func (r *group) stats() []uint64 {
+	hits := make([]uint64, 0, len(r.hits))
+	for i := range r.hits {
+		hits = append(hits, atomic.LoadUint64(&r.hits[i]))
+	}
+	return hits
+}
@@ -0,0 +1,122 @@
+package hedgehog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type tsamples struct {
+	Delayer
+	samples int64
+}
+
+func (d *tsamples) Record(latency time.Duration) {
+	atomic.AddInt64(&d.samples, 1)
+	d.Delayer.Record(latency)
+}
+
+func TestResourceGroup(t *testing.T) {
+	const users, accounts = 6, 4
+	delayer := &tsamples{Delayer: NewDelayerAverage(ms_100, users+accounts)}
+	rs := NewResourceWithOptions(
+		NewResourceGroup(
+			delayer,
+			NewChecker(http.StatusOK),
+			NewMatcher(http.MethodGet, regexp.MustCompile(`/v1/users/[0-9]+`)),
+			NewMatcher(http.MethodGet, regexp.MustCompile(`/v1/accounts/[0-9]+`)),
+		),
+		ResourceWithName("pool"),
+	)
+	rec := newRecorder(ms_10)
+	rt := NewRoundTripper(rec, 1, rs)
+	var wg sync.WaitGroup
+	call := func(path string) {
+		defer wg.Done()
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Errorf("expected nil err but got %v", err)
+		}
+	}
+	for i := 0; i < users; i++ {
+		wg.Add(2)
+		go call("/v1/users/1")
+		go call("/v1/profiles/1")
+	}
+	for i := 0; i < accounts; i++ {
+		wg.Add(1)
+		go call("/v1/accounts/1")
+	}
+	wg.Wait()
+	if samples := atomic.LoadInt64(&delayer.samples); samples != users+accounts {
+		t.Fatalf("expected %d combined samples but got %d", users+accounts, samples)
+	}
+	stats, _ := GetStats(rt)
+	rstats := stats.Resources[0]
+	if rstats.Name != "pool" || !reflect.DeepEqual(rstats.Hits, []uint64{users, accounts}) {
+		t.Fatalf("expected pool resource stats with hits %v but got %+v", []uint64{users, accounts}, rstats)
+	}
+	// combined samples are enough for the shared delay to converge to upstream latency.
+	if rstats.Delay < ms_10 || rstats.Delay >= ms_100 {
+		t.Fatalf("expected converged delay but got %v", rstats.Delay)
+	}
+	if rec.calls["/v1/profiles/1"] != users {
+		t.Fatalf("expected non matching requests not to be hedged but got %d calls", rec.calls["/v1/profiles/1"])
+	}
+}
+
+func TestResourceGroupCheck(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusInternalServerError}
+	if err := NewResourceGroup(NewDelayerStatic(ms_1), nil).Check(resp); err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	err := NewResourceGroup(NewDelayerStatic(ms_1), NewChecker(http.StatusOK)).Check(resp)
+	if !errors.Is(err, ErrResourceUnexpectedResponseCode{StatusCode: http.StatusInternalServerError}) {
+		t.Fatalf("expected unexpected response code err but got %v", err)
+	}
+}
+
+func TestResourceGroupNilDelayer(t *testing.T) {
+	rs := NewResourceGroup(nil, nil, NewMatcher(http.MethodGet, nil))
+	select {
+	case <-rs.After():
+	case <-time.After(ms_50):
+		t.Fatal("expected no delay with nil delayer")
+	}
+}
+
+type tcounted struct {
+	Delayer
+	delays int64
+}
+
+func (d *tcounted) Delay() time.Duration {
+	atomic.AddInt64(&d.delays, 1)
+	return d.Delayer.Delay()
+}
+
+func TestResourceGroupDelayOnce(t *testing.T) {
+	delayer := &tcounted{Delayer: NewDelayerStatic(ms_5)}
+	rt := NewTransport(
+		newRecorder(ms_10),
+		WithCalls(1),
+		WithResources(NewResourceGroup(delayer, nil, NewMatcher(http.MethodGet, nil))),
+		WithTransportObserver(ObserverFunc(func(Event) {})),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/profile", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	// the delay is estimated once per request no matter how many hedging decisions depend on it.
+	if d := atomic.LoadInt64(&delayer.delays); d != 1 {
+		t.Fatalf("expected single delay estimation but got %d", d)
+	}
+}
@@ -0,0 +1,124 @@
+package hedgehog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// hopHeaders defines standard hop-by-hop headers, see RFC 7230 section 6.1.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// WithAttemptModifier sets hedged transport attempt modifier that is called right before each original and hedged http call
+// of matched requests with the call attempt launch order index, see `AttemptFromContext`. Modifier is called on the call
+// own request copy, so the call request modifications never leak to other calls. It's useful to tweak hedged calls,
+// e.g. to set attempt header or to switch tracing flags. Modifier is called concurrently from multiple goroutines.
+func WithAttemptModifier(modifier func(attempt int, req *http.Request)) TransportOption {
+	return func(t *transport) {
+		t.modifier = modifier
+	}
+}
+
+// WithStripHeadersOnHedge sets hedged transport headers that are removed from hedged calls requests,
+// while original calls requests keep them, e.g. one time tokens that are invalidated after the first use.
+// Header names are matched canonically. Note that standard hop-by-hop headers and headers listed in connection header
+// are always removed from all original and hedged calls requests of matched requests, except "Te: trailers".
+func WithStripHeadersOnHedge(names ...string) TransportOption {
+	return func(t *transport) {
+		t.strip = make([]string, 0, len(names))
+		for _, name := range names {
+			t.strip = append(t.strip, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// unhop removes hop-by-hop headers from provided request, provided request must be owned by the caller.
+func unhop(req *http.Request) {
+	for _, value := range req.Header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				req.Header.Del(name)
+			}
+		}
+	}
+	trailers := false
+	for _, value := range req.Header.Values("Te") {
+		for _, token := range strings.Split(value, ",") {
+			trailers = trailers || strings.EqualFold(strings.TrimSpace(token), "trailers")
+		}
+	}
+	for _, name := range hopHeaders {
+		req.Header.Del(name)
+	}
+	if trailers {
+		req.Header.Set("Te", "trailers")
+	}
+}
+
+// modify applies transport attempt modifications to provided request, provided request must be owned by the caller.
+func (t *transport) modify(req *http.Request, attempt int) {
+	unhop(req)
+	if attempt > 0 {
+		for _, name := range t.strip {
+			req.Header.Del(name)
+		}
+	}
+	if t.stamp != nil && t.stamp.attempt != "" {
+		req.Header.Set(t.stamp.attempt, strconv.Itoa(attempt))
+	}
+	if t.modifier != nil {
+		t.modifier(attempt, req)
+	}
+}
+
+type stamp struct {
+	correlation string
+	attempt     string
+	id          func() string
+}
+
+// WithHedgeHeaders sets hedged transport headers that let upstream servers measure and deduplicate hedged calls,
+// each original and hedged http call of matched requests carries provided correlation header with random id
+// generated once per request and provided attempt header with the call attempt launch order index, see `AttemptFromContext`.
+// Empty header names are never set.
+func WithHedgeHeaders(correlationHeader, attemptHeader string) TransportOption {
+	return withHedgeHeaders(correlationHeader, attemptHeader, correlationID)
+}
+
+func withHedgeHeaders(correlationHeader, attemptHeader string, id func() string) TransportOption {
+	return func(t *transport) {
+		if correlationHeader == "" && attemptHeader == "" {
+			t.stamp = nil
+			return
+		}
+		t.stamp = &stamp{correlation: correlationHeader, attempt: attemptHeader, id: id}
+	}
+}
+
+func correlationID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// correlate returns provided request copy carrying new correlation id header, so all its http calls share it.
+func (t *transport) correlate(req *http.Request) *http.Request {
+	if t.stamp == nil || t.stamp.correlation == "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(t.stamp.correlation, t.stamp.id())
+	return req
+}
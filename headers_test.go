@@ -0,0 +1,197 @@
+package hedgehog
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// theaders records request headers received by each attempt.
+type theaders struct {
+	lock     sync.Mutex
+	wg       sync.WaitGroup
+	last     int
+	attempts map[int]http.Header
+}
+
+func newHeaders(attempts int) *theaders {
+	h := &theaders{last: attempts - 1, attempts: make(map[int]http.Header)}
+	h.wg.Add(attempts)
+	return h
+}
+
+func (h *theaders) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempt, _ := AttemptFromContext(req.Context())
+	h.lock.Lock()
+	h.attempts[attempt] = req.Header.Clone()
+	h.lock.Unlock()
+	h.wg.Done()
+	// only the last hedged call succeeds once all attempts are made.
+	if attempt != h.last {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	h.wg.Wait()
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestWithAttemptModifier(t *testing.T) {
+	internal := newHeaders(4)
+	rt := NewTransport(
+		internal,
+		WithCalls(3),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+		WithAttemptModifier(func(attempt int, req *http.Request) {
+			req.Header.Add("X-Attempt", strconv.Itoa(attempt))
+		}),
+	)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	_ = resp.Body.Close()
+	if len(req.Header) != 0 {
+		t.Fatalf("expected original request headers not to be modified but got %v", req.Header)
+	}
+	internal.lock.Lock()
+	defer internal.lock.Unlock()
+	for attempt := 0; attempt <= 3; attempt++ {
+		// modifications never leak between attempts.
+		if h := internal.attempts[attempt]["X-Attempt"]; len(h) != 1 || h[0] != strconv.Itoa(attempt) {
+			t.Fatalf("expected attempt %d header %q but got %v", attempt, strconv.Itoa(attempt), h)
+		}
+	}
+}
+
+func TestWithHedgeHeaders(t *testing.T) {
+	var ids []string
+	gen := func() string {
+		ids = append(ids, strconv.Itoa(len(ids)))
+		return ids[len(ids)-1]
+	}
+	var internal *theaders
+	rt := NewTransport(
+		RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return internal.RoundTrip(req)
+		}),
+		WithCalls(3),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+		withHedgeHeaders("X-Correlation-Id", "X-Attempt", gen),
+	)
+	// different requests get different ids.
+	for _, id := range []string{"0", "1"} {
+		internal = newHeaders(4)
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+		_ = resp.Body.Close()
+		if len(req.Header) != 0 {
+			t.Fatalf("expected original request headers not to be modified but got %v", req.Header)
+		}
+		internal.lock.Lock()
+		for attempt := 0; attempt <= 3; attempt++ {
+			h := internal.attempts[attempt]
+			if c := h.Get("X-Correlation-Id"); c != id {
+				t.Fatalf("expected attempt %d correlation id %q but got %q", attempt, id, c)
+			}
+			if a := h.Get("X-Attempt"); a != strconv.Itoa(attempt) {
+				t.Fatalf("expected attempt %d attempt header %q but got %q", attempt, strconv.Itoa(attempt), a)
+			}
+		}
+		internal.lock.Unlock()
+	}
+	// disabled hedge headers are never set.
+	internal = newHeaders(2)
+	rt = NewTransport(
+		internal,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+		WithHedgeHeaders("", ""),
+	)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	internal.lock.Lock()
+	defer internal.lock.Unlock()
+	for attempt, h := range internal.attempts {
+		if len(h) != 0 {
+			t.Fatalf("expected attempt %d to have no headers but got %v", attempt, h)
+		}
+	}
+}
+
+func TestWithStripHeadersOnHedge(t *testing.T) {
+	internal := newHeaders(3)
+	rt := NewTransport(
+		internal,
+		WithCalls(2),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+		WithStripHeadersOnHedge("x-csrf-token"),
+	)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+	req.Header.Set("X-Csrf-Token", "token")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Connection", "keep-alive, X-Hop")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("X-Hop", "hop")
+	req.Header.Set("Te", "trailers, deflate")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	_ = resp.Body.Close()
+	if len(req.Header) != 6 {
+		t.Fatalf("expected original request headers not to be modified but got %v", req.Header)
+	}
+	internal.lock.Lock()
+	defer internal.lock.Unlock()
+	for attempt := 0; attempt <= 2; attempt++ {
+		h := internal.attempts[attempt]
+		expected := http.Header{"Accept": {"application/json"}, "Te": {"trailers"}}
+		if attempt == 0 {
+			expected.Set("X-Csrf-Token", "token")
+		}
+		if !reflect.DeepEqual(h, expected) {
+			t.Fatalf("expected attempt %d headers %v but got %v", attempt, expected, h)
+		}
+	}
+}
+
+func TestHedgeUnhopHeaders(t *testing.T) {
+	internal := newHeaders(3)
+	rt := NewTransport(
+		internal,
+		WithCalls(2),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+	)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Connection", "keep-alive, X-Hop")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("X-Hop", "hop")
+	req.Header.Set("Te", "trailers")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	_ = resp.Body.Close()
+	if len(req.Header) != 5 {
+		t.Fatalf("expected original request headers not to be modified but got %v", req.Header)
+	}
+	internal.lock.Lock()
+	defer internal.lock.Unlock()
+	for attempt := 0; attempt <= 2; attempt++ {
+		h := internal.attempts[attempt]
+		expected := http.Header{"Accept": {"application/json"}, "Te": {"trailers"}}
+		if !reflect.DeepEqual(h, expected) {
+			t.Fatalf("expected attempt %d headers %v but got %v", attempt, expected, h)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/1pkg/hedgehog/hedgehoggrpc
+
+go 1.16
+
+require (
+	github.com/1pkg/hedgehog v0.0.0
+	google.golang.org/grpc v1.46.2
+	google.golang.org/protobuf v1.27.1
+)
+
+replace github.com/1pkg/hedgehog => ../
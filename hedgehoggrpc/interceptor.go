@@ -0,0 +1,203 @@
+// Package hedgehoggrpc provides hedged grpc unary client interceptor built on top of hedgehog hedging policies.
+package hedgehoggrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/1pkg/hedgehog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Method defines hedged grpc method.
+type Method struct {
+	// FullMethod defines grpc full method name, e.g. `/package.Service/Method`.
+	FullMethod string
+	// Delayer defines hedged calls delay policy of the method.
+	Delayer hedgehog.Delayer
+	// Codes defines allowed grpc status codes of the method, if none provided only `codes.OK` is allowed.
+	Codes []codes.Code
+}
+
+type method struct {
+	delayer hedgehog.Delayer
+	codes   map[codes.Code]bool
+}
+
+type interceptor struct {
+	calls    uint64
+	methods  map[string]method
+	budget   *hedgehog.Budget
+	limiter  hedgehog.HedgeLimiter
+	observer hedgehog.Observer
+}
+
+// Option defines hedged grpc interceptor option.
+type Option func(*interceptor)
+
+// WithCalls sets hedged interceptor calls number.
+func WithCalls(calls uint64) Option {
+	return func(ic *interceptor) {
+		ic.calls = calls
+	}
+}
+
+// WithMethods adds provided methods to hedged interceptor methods,
+// methods with the same full method name replace previously added ones.
+func WithMethods(methods ...Method) Option {
+	return func(ic *interceptor) {
+		for _, m := range methods {
+			allowed := m.Codes
+			if len(allowed) == 0 {
+				allowed = []codes.Code{codes.OK}
+			}
+			mt := method{delayer: m.Delayer, codes: make(map[codes.Code]bool, len(allowed))}
+			if mt.delayer == nil {
+				mt.delayer = hedgehog.NewDelayerStatic(0)
+			}
+			for _, code := range allowed {
+				mt.codes[code] = true
+			}
+			ic.methods[m.FullMethod] = mt
+		}
+	}
+}
+
+// WithBudget makes hedged interceptor calls debit provided budget,
+// hedged calls are skipped once the budget is exhausted.
+// The same budget could be shared with hedged http transport resources.
+func WithBudget(b *hedgehog.Budget) Option {
+	return func(ic *interceptor) {
+		ic.budget = b
+	}
+}
+
+// WithLimiter sets hedged interceptor rate limiter that paces hedged calls of all matched grpc calls,
+// the limiter is consulted right before each hedged call once the budget allows it and hedged calls it denies are skipped.
+// The same limiter could be shared with hedged http transport, see `hedgehog.WithHedgeLimiter`.
+func WithLimiter(l hedgehog.HedgeLimiter) Option {
+	return func(ic *interceptor) {
+		ic.limiter = l
+	}
+}
+
+// WithObserver sets hedged interceptor lifecycle events observer.
+// Emitted events have neither request nor resource set, grpc status codes are reported as status codes.
+func WithObserver(obs hedgehog.Observer) Option {
+	return func(ic *interceptor) {
+		ic.observer = obs
+	}
+}
+
+// NewUnaryClientInterceptor returns new hedged grpc unary client interceptor configured with provided options.
+// Returned interceptor makes hedged grpc calls in case of method matching grpc call full method name up to calls+1 times,
+// original grpc call starts right away and then all hedged calls start together after delay specified by method delayer.
+// Returned interceptor returns first grpc call result with allowed status code all other calls in flight are canceled,
+// in case all hedged calls failed it simply returns first occurred error.
+// If no matching methods were found - the interceptor simply invokes the call.
+// Streaming calls are never hedged.
+func NewUnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+	ic := &interceptor{methods: make(map[string]method)}
+	for _, opt := range opts {
+		opt(ic)
+	}
+	return ic.intercept
+}
+
+type result struct {
+	attempt uint64
+	reply   proto.Message
+	err     error
+	ok      bool
+}
+
+func (ic *interceptor) intercept(
+	ctx context.Context,
+	fullMethod string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	m, ok := ic.methods[fullMethod]
+	out, msg := reply.(proto.Message)
+	if !ok || !msg {
+		return invoker(ctx, fullMethod, req, reply, cc, opts...)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	res := make(chan result, ic.calls+1)
+	call := func(attempt uint64) {
+		// each call needs its own reply message as calls are racing.
+		rep := out.ProtoReflect().New().Interface()
+		ic.emit(hedgehog.Event{Kind: hedgehog.EventAttemptStart, Attempt: int(attempt)})
+		go func() {
+			start := time.Now()
+			err := invoker(ctx, fullMethod, req, rep, cc, opts...)
+			code := status.Code(err)
+			ok := m.codes[code]
+			if ok {
+				m.delayer.Record(time.Since(start))
+			}
+			ic.emit(hedgehog.Event{
+				Kind:       hedgehog.EventAttemptEnd,
+				Attempt:    int(attempt),
+				Elapsed:    time.Since(start),
+				StatusCode: int(code),
+				Err:        err,
+			})
+			res <- result{attempt: attempt, reply: rep, err: err, ok: ok}
+		}()
+	}
+	delay := m.delayer.Delay()
+	ic.emit(hedgehog.Event{Kind: hedgehog.EventDelay, Delay: delay})
+	if ic.budget != nil {
+		ic.budget.Primary()
+	}
+	call(0)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	after := timer.C
+	var err error
+	for pending := ic.calls + 1; pending > 0; {
+		select {
+		case <-after:
+			after = nil
+			for i := uint64(1); i <= ic.calls; i++ {
+				if (ic.budget != nil && !ic.budget.Allow()) || (ic.limiter != nil && !ic.limiter.Allow()) {
+					ic.emit(hedgehog.Event{Kind: hedgehog.EventHedgeSkipped, Attempt: int(i)})
+					pending--
+					continue
+				}
+				call(i)
+			}
+		case r := <-res:
+			pending--
+			if r.ok {
+				proto.Reset(out)
+				proto.Merge(out, r.reply)
+				ic.emit(hedgehog.Event{Kind: hedgehog.EventWinner, Attempt: int(r.attempt), StatusCode: int(status.Code(r.err))})
+				return r.err
+			}
+			// keep only first occurred error.
+			if err == nil {
+				err = r.err
+			}
+		}
+	}
+	ic.emit(hedgehog.Event{Kind: hedgehog.EventFailure, Err: err})
+	return err
+}
+
+func (ic *interceptor) emit(e hedgehog.Event) {
+	if ic.observer == nil {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	ic.observer.Observe(e)
+}
@@ -0,0 +1,190 @@
+package hedgehoggrpc
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/1pkg/hedgehog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+// thealth defines test health server whose first call hangs until canceled and the rest respond right away.
+type thealth struct {
+	healthpb.UnimplementedHealthServer
+	calls    int32
+	code     codes.Code
+	canceled chan struct{}
+}
+
+func (s *thealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if atomic.AddInt32(&s.calls, 1) == 1 {
+		<-ctx.Done()
+		close(s.canceled)
+		return nil, ctx.Err()
+	}
+	if s.code != codes.OK {
+		return nil, status.Error(s.code, req.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func dial(t *testing.T, srv *thealth, ic grpc.UnaryClientInterceptor) healthpb.HealthClient {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, srv)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(ic),
+	)
+	if err != nil {
+		t.Fatalf("expected nil dial err but got %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		s.Stop()
+	})
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	var winner int32 = -1
+	srv := &thealth{canceled: make(chan struct{})}
+	ic := NewUnaryClientInterceptor(
+		WithCalls(1),
+		WithMethods(Method{FullMethod: checkMethod, Delayer: hedgehog.NewDelayerStatic(10 * time.Millisecond)}),
+		WithObserver(hedgehog.ObserverFunc(func(e hedgehog.Event) {
+			if e.Kind == hedgehog.EventWinner {
+				atomic.StoreInt32(&winner, int32(e.Attempt))
+			}
+		})),
+	)
+	client := dial(t, srv, ic)
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected serving status but got %v", resp.Status)
+	}
+	if w := atomic.LoadInt32(&winner); w != 1 {
+		t.Fatalf("expected hedged call to win but got attempt %d", w)
+	}
+	select {
+	case <-srv.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected original call to be canceled")
+	}
+	if calls := atomic.LoadInt32(&srv.calls); calls != 2 {
+		t.Fatalf("expected 2 server calls but got %d", calls)
+	}
+}
+
+func TestUnaryClientInterceptorAllowedCodes(t *testing.T) {
+	srv := &thealth{code: codes.NotFound, canceled: make(chan struct{})}
+	ic := NewUnaryClientInterceptor(
+		WithCalls(1),
+		WithMethods(Method{
+			FullMethod: checkMethod,
+			Delayer:    hedgehog.NewDelayerStatic(10 * time.Millisecond),
+			Codes:      []codes.Code{codes.OK, codes.NotFound},
+		}),
+	)
+	client := dial(t, srv, ic)
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "svc"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected not found err but got %v", err)
+	}
+	// hedged call allowed result wins so original call is canceled.
+	<-srv.canceled
+}
+
+func TestUnaryClientInterceptorBudget(t *testing.T) {
+	srv := &thealth{canceled: make(chan struct{})}
+	ic := NewUnaryClientInterceptor(
+		WithCalls(1),
+		WithMethods(Method{FullMethod: checkMethod, Delayer: hedgehog.NewDelayerStatic(10 * time.Millisecond)}),
+		WithBudget(hedgehog.NewHedgeBudget("grpc", 0, time.Minute)),
+	)
+	client := dial(t, srv, ic)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded err but got %v", err)
+	}
+	if calls := atomic.LoadInt32(&srv.calls); calls != 1 {
+		t.Fatalf("expected 1 server call but got %d", calls)
+	}
+}
+
+// tlimiter defines test hedged calls limiter that allows provided number of permits.
+type tlimiter struct {
+	permits int64
+	calls   int64
+}
+
+func (l *tlimiter) Allow() bool {
+	atomic.AddInt64(&l.calls, 1)
+	return atomic.AddInt64(&l.permits, -1) >= 0
+}
+
+func TestUnaryClientInterceptorLimiter(t *testing.T) {
+	var skipped int32
+	srv := &thealth{canceled: make(chan struct{})}
+	limiter := &tlimiter{permits: 1}
+	ic := NewUnaryClientInterceptor(
+		WithCalls(3),
+		WithMethods(Method{FullMethod: checkMethod, Delayer: hedgehog.NewDelayerStatic(0)}),
+		WithLimiter(limiter),
+		WithObserver(hedgehog.ObserverFunc(func(e hedgehog.Event) {
+			if e.Kind == hedgehog.EventHedgeSkipped {
+				atomic.AddInt32(&skipped, 1)
+			}
+		})),
+	)
+	client := dial(t, srv, ic)
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	// limiter permits single hedged call out of three, so the rest are skipped.
+	if c := atomic.LoadInt64(&limiter.calls); c != 3 {
+		t.Fatalf("expected 3 limiter calls but got %d", c)
+	}
+	if s := atomic.LoadInt32(&skipped); s != 2 {
+		t.Fatalf("expected 2 skipped hedged calls but got %d", s)
+	}
+	if calls := atomic.LoadInt32(&srv.calls); calls != 2 {
+		t.Fatalf("expected 2 server calls but got %d", calls)
+	}
+}
+
+func TestUnaryClientInterceptorPassThrough(t *testing.T) {
+	// skip hanging first server call as non matching calls are never hedged.
+	srv := &thealth{calls: 1, canceled: make(chan struct{})}
+	ic := NewUnaryClientInterceptor(
+		WithCalls(3),
+		WithMethods(Method{FullMethod: "/grpc.health.v1.Health/Other"}),
+	)
+	client := dial(t, srv, ic)
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	if calls := atomic.LoadInt32(&srv.calls); calls != 2 {
+		t.Fatalf("expected 1 server call but got %d", calls-1)
+	}
+}
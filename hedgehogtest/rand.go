@@ -0,0 +1,33 @@
+// Package hedgehogtest provides utilities for testing code that uses hedgehog hedged transport.
+package hedgehogtest
+
+import (
+	"sync"
+)
+
+// Sequence defines deterministic random numbers source that cycles through provided values,
+// it could be provided to hedged transport and resources instead of random numbers source
+// to make their probabilistic decisions reproducible.
+type Sequence struct {
+	lock   sync.Mutex
+	values []float64
+	next   int
+}
+
+// NewSequence returns new deterministic random numbers source that cycles through provided values,
+// if no values are provided it always returns 0.
+func NewSequence(values ...float64) *Sequence {
+	return &Sequence{values: values}
+}
+
+// Float64 returns the next sequence value.
+func (s *Sequence) Float64() float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.values) == 0 {
+		return 0
+	}
+	v := s.values[s.next]
+	s.next = (s.next + 1) % len(s.values)
+	return v
+}
@@ -0,0 +1,33 @@
+package hedgehogtest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSequence(t *testing.T) {
+	ttable := map[string]struct {
+		values   []float64
+		expected []float64
+	}{
+		"should always return zero for empty sequence": {
+			expected: []float64{0, 0, 0},
+		},
+		"should cycle through sequence values": {
+			values:   []float64{0.1, 0.5, 0.9},
+			expected: []float64{0.1, 0.5, 0.9, 0.1, 0.5},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			seq := NewSequence(tcase.values...)
+			values := make([]float64, 0, len(tcase.expected))
+			for range tcase.expected {
+				values = append(values, seq.Float64())
+			}
+			if !reflect.DeepEqual(values, tcase.expected) {
+				t.Fatalf("expected values %v but got %v", tcase.expected, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+)
+
+// indexThreshold defines min number of resources starting from which resources are indexed.
+const indexThreshold = 8
+
+// index defines resources index by http method and url literal prefix,
+// it narrows down resources that might match a request while preserving resources precedence.
+type index struct {
+	methods map[string]*bucket
+	// any contains resources that might match requests with any http method.
+	any *bucket
+}
+
+type bucket struct {
+	// linear contains resources without derivable url literal prefix.
+	linear []int
+	root   *trie
+}
+
+type trie struct {
+	resources []int
+	next      map[byte]*trie
+}
+
+func newIndex(resources []Resource) *index {
+	idx := &index{methods: make(map[string]*bucket), any: &bucket{root: &trie{}}}
+	for i, rs := range resources {
+		st, ok := staticOf(rs)
+		if !ok {
+			// non static resources are added to every bucket to keep their precedence.
+			idx.any.add(i, "", false)
+			for _, b := range idx.methods {
+				b.add(i, "", false)
+			}
+			continue
+		}
+		b, ok := idx.methods[st.method]
+		if !ok {
+			b = idx.any.clone()
+			idx.methods[st.method] = b
+		}
+		prefix, ok := prefixOf(st.url)
+		b.add(i, prefix, ok)
+	}
+	return idx
+}
+
+func (b *bucket) add(i int, prefix string, ok bool) {
+	if !ok {
+		b.linear = append(b.linear, i)
+		return
+	}
+	node := b.root
+	for j := 0; j < len(prefix); j++ {
+		if node.next == nil {
+			node.next = make(map[byte]*trie)
+		}
+		next, ok := node.next[prefix[j]]
+		if !ok {
+			next = &trie{}
+			node.next[prefix[j]] = next
+		}
+		node = next
+	}
+	node.resources = append(node.resources, i)
+}
+
+func (b *bucket) clone() *bucket {
+	return &bucket{linear: append([]int(nil), b.linear...), root: &trie{}}
+}
+
+// candidates returns indexes of resources that might match provided request url in resources order.
+func (b *bucket) candidates(url string) []int {
+	candidates := append([]int(nil), b.linear...)
+	node := b.root
+	candidates = append(candidates, node.resources...)
+	for j := 0; j < len(url) && node.next != nil; j++ {
+		if node = node.next[url[j]]; node == nil {
+			break
+		}
+		candidates = append(candidates, node.resources...)
+	}
+	sort.Ints(candidates)
+	return candidates
+}
+
+// match returns the first resource matching provided request position.
+func (idx *index) match(resources []Resource, req *http.Request) (int, bool) {
+	b, ok := idx.methods[req.Method]
+	if !ok {
+		b = idx.any
+	}
+	if len(b.linear) == 0 && b.root.next == nil && len(b.root.resources) == 0 {
+		return 0, false
+	}
+	url := ""
+	if b.root.next != nil {
+		url = req.URL.String()
+	}
+	for _, i := range b.candidates(url) {
+		if resources[i].Match(req) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// prefixOf returns url literal prefix that any url matched by provided regexp starts with,
+// if no such prefix could be derived it returns false.
+func prefixOf(url *regexp.Regexp) (string, bool) {
+	if url == nil {
+		return "", false
+	}
+	re, err := syntax.Parse(url.String(), syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) == 0 || re.Sub[0].Op != syntax.OpBeginText {
+		return "", false
+	}
+	var prefix []rune
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		prefix = append(prefix, sub.Rune...)
+	}
+	if len(prefix) == 0 {
+		return "", false
+	}
+	return string(prefix), true
+}
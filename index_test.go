@@ -0,0 +1,117 @@
+package hedgehog
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestPrefixOf(t *testing.T) {
+	ttable := map[string]struct {
+		prefix string
+		ok     bool
+	}{
+		``:                                  {},
+		`profile`:                           {},
+		`.*profile`:                         {},
+		`(?i)^http://example.com`:           {},
+		`(?m)^http://example.com`:           {},
+		`^http://a.com/x|^http://b.com/y`:   {},
+		`^http://example\.com/users`:        {prefix: "http://example.com/users", ok: true},
+		`^http://example\.com/users/[0-9]+`: {prefix: "http://example.com/users/", ok: true},
+		`^http://example\.com/users$`:       {prefix: "http://example.com/users", ok: true},
+		`^https?://example\.com`:            {prefix: "http", ok: true},
+	}
+	for pattern, tcase := range ttable {
+		t.Run(pattern, func(t *testing.T) {
+			prefix, ok := prefixOf(regexp.MustCompile(pattern))
+			if prefix != tcase.prefix || ok != tcase.ok {
+				t.Fatalf("expected prefix %q %v but got %q %v", tcase.prefix, tcase.ok, prefix, ok)
+			}
+		})
+	}
+}
+
+func trandResources(rnd *rand.Rand, n int) []Resource {
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut}
+	hosts := []string{"http://a.com", "http://b.com", "https://a.com"}
+	paths := []string{"/users", "/users/1", "/accounts", "/accounts/2", "/"}
+	resources := make([]Resource, 0, n)
+	for i := 0; i < n; i++ {
+		method := methods[rnd.Intn(len(methods))]
+		host, path := hosts[rnd.Intn(len(hosts))], paths[rnd.Intn(len(paths))]
+		var url *regexp.Regexp
+		switch rnd.Intn(6) {
+		case 0:
+		case 1:
+			url = regexp.MustCompile(regexp.QuoteMeta(path))
+		case 2:
+			url = regexp.MustCompile(`^` + regexp.QuoteMeta(host+path))
+		case 3:
+			url = regexp.MustCompile(`^` + regexp.QuoteMeta(host+path) + `$`)
+		case 4:
+			url = regexp.MustCompile(`^` + regexp.QuoteMeta(host) + `/[a-z]+/[0-9]+`)
+		case 5:
+			resources = append(resources, NewResourceGroup(NewDelayerStatic(ms_0), nil, NewMatcher(method, regexp.MustCompile(regexp.QuoteMeta(path)))))
+			continue
+		}
+		// decorated resources are comparable unlike static ones.
+		resources = append(resources, NewResourceWithOptions(NewResourceStatic(method, url, ms_0, http.StatusOK)))
+	}
+	return resources
+}
+
+func TestIndexMatch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	hosts := []string{"http://a.com", "http://b.com", "https://a.com", "https://c.com"}
+	paths := []string{"/users", "/users/1", "/accounts", "/accounts/2", "/", "/items/3", "/users/1/accounts"}
+	for _, n := range []int{1, 10, 50, 200} {
+		resources := trandResources(rnd, n)
+		t.Run(fmt.Sprintf("%d resources", n), func(t *testing.T) {
+			linear := &transport{resources: resources}
+			indexed := &transport{resources: resources, index: newIndex(resources)}
+			var matched int
+			for i := 0; i < 1000; i++ {
+				url := hosts[rnd.Intn(len(hosts))] + paths[rnd.Intn(len(paths))]
+				req, _ := http.NewRequest(methods[rnd.Intn(len(methods))], url, nil)
+				lrs, lok := linear.match(req)
+				irs, iok := indexed.match(req)
+				if lrs != irs || lok != iok {
+					t.Fatalf("expected indexed match %v %v to be equal to linear match %v %v for %s %s", irs, iok, lrs, lok, req.Method, url)
+				}
+				if lok {
+					matched++
+				}
+			}
+			if matched == 0 {
+				t.Fatal("expected some requests to be matched")
+			}
+		})
+	}
+}
+
+func BenchmarkTransportMatch(b *testing.B) {
+	for _, n := range []int{10, 100, 500} {
+		resources := make([]Resource, 0, n)
+		for i := 0; i < n; i++ {
+			url := regexp.MustCompile(fmt.Sprintf(`^http://example\.com/v1/resource%d/[0-9]+`, i))
+			resources = append(resources, NewResourceStatic(http.MethodGet, url, ms_0, http.StatusOK))
+		}
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/v1/resource%d/42", n-1), nil)
+		linear := &transport{resources: resources}
+		indexed := &transport{resources: resources, index: newIndex(resources)}
+		b.Run(fmt.Sprintf("linear %d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = linear.match(req)
+			}
+		})
+		b.Run(fmt.Sprintf("indexed %d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = indexed.match(req)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package hedgehog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MaxJSONBodySize defines max response body size accepted by json helpers.
+const MaxJSONBodySize = 10 << 20
+
+const jsonSnippetSize = 256
+
+// ErrJSONCall defines json helper error that is returned on any failed json call.
+// It carries the call details, status code, content type and response body snippet when response was received,
+// as well as attempt launch order index of the http call that produced the response or the error, see `AttemptFromContext`,
+// and whether that http call was hedged call rather than original call.
+type ErrJSONCall struct {
+	Method      string
+	URL         string
+	StatusCode  int
+	ContentType string
+	Snippet     string
+	Attempt     int
+	Hedged      bool
+	Err         error
+}
+
+func (err ErrJSONCall) Error() string {
+	if err.StatusCode == 0 {
+		return fmt.Sprintf("json call %s %s failed: %v", err.Method, err.URL, err.Err)
+	}
+	return fmt.Sprintf(
+		"json call %s %s failed: received response status code %d content type %q: %v",
+		err.Method,
+		err.URL,
+		err.StatusCode,
+		err.ContentType,
+		err.Err,
+	)
+}
+
+func (err ErrJSONCall) Unwrap() error {
+	return err.Err
+}
+
+// GetJSON makes GET http call to provided url through provided client and returns json response body decoded into value of type T.
+// Provided call options are applied to the request context and override transport hedging behavior for this call.
+// Response is expected to have json content type, 2xx status code and body not bigger than `MaxJSONBodySize`,
+// otherwise `ErrJSONCall` is returned.
+// If nil client is provided default client will be used.
+func GetJSON[T any](ctx context.Context, client *http.Client, url string, opts ...CallOption) (T, error) {
+	var v T
+	err := doJSON(ctx, client, http.MethodGet, url, nil, &v, opts...)
+	return v, err
+}
+
+// PostJSON makes POST http call to provided url through provided client with json encoded provided body
+// and returns json response body decoded into value of type T.
+// Provided call options are applied to the request context and override transport hedging behavior for this call.
+// Response is expected to have json content type, 2xx status code and body not bigger than `MaxJSONBodySize`,
+// otherwise `ErrJSONCall` is returned.
+// If nil client is provided default client will be used.
+func PostJSON[T any](ctx context.Context, client *http.Client, url string, body interface{}, opts ...CallOption) (T, error) {
+	var v T
+	b, err := json.Marshal(body)
+	if err != nil {
+		return v, ErrJSONCall{Method: http.MethodPost, URL: url, Err: err}
+	}
+	err = doJSON(ctx, client, http.MethodPost, url, b, &v, opts...)
+	return v, err
+}
+
+func doJSON(ctx context.Context, client *http.Client, method, url string, body []byte, v interface{}, opts ...CallOption) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(withCallOptions(ctx, opts...), method, url, r)
+	if err != nil {
+		return ErrJSONCall{Method: method, URL: url, Err: err}
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		cerr := ErrJSONCall{Method: method, URL: url, Err: err}
+		var aerr ErrAttemptFailed
+		if errors.As(err, &aerr) {
+			cerr.Attempt, cerr.Hedged = aerr.Attempt, aerr.Attempt > 0
+		}
+		return cerr
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, MaxJSONBodySize+1))
+	cerr := ErrJSONCall{
+		Method:      method,
+		URL:         url,
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Snippet:     snippet(b),
+	}
+	if resp.Request != nil {
+		cerr.Attempt, _ = AttemptFromContext(resp.Request.Context())
+		cerr.Hedged = cerr.Attempt > 0
+	}
+	switch {
+	case err != nil:
+		cerr.Err = err
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		cerr.Err = ErrResourceUnexpectedResponseCode{StatusCode: resp.StatusCode}
+	case !isJSON(cerr.ContentType):
+		cerr.Err = fmt.Errorf("unexpected content type %q", cerr.ContentType)
+	case len(b) > MaxJSONBodySize:
+		cerr.Err = fmt.Errorf("response body exceeds %d bytes", MaxJSONBodySize)
+	default:
+		if err := json.Unmarshal(b, v); err != nil {
+			cerr.Err = err
+			return cerr
+		}
+		return nil
+	}
+	return cerr
+}
+
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+func snippet(b []byte) string {
+	if len(b) > jsonSnippetSize {
+		b = b[:jsonSnippetSize]
+	}
+	return string(b)
+}
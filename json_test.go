@@ -0,0 +1,160 @@
+package hedgehog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type tprofile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func tjsonserv(ctype string, bodies []string, delays []time.Duration) (string, context.CancelFunc) {
+	var i int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt64(&i, 1) - 1
+		if n < int64(len(delays)) {
+			time.Sleep(delays[n])
+		}
+		if req.Method == http.MethodPost {
+			b, _ := io.ReadAll(req.Body)
+			w.Header().Set("Content-Type", ctype)
+			_, _ = w.Write(b)
+			return
+		}
+		w.Header().Set("Content-Type", ctype)
+		_, _ = io.WriteString(w, bodies[int(n)%len(bodies)])
+	}))
+	return srv.URL, srv.Close
+}
+
+func TestJSON(t *testing.T) {
+	ttable := map[string]struct {
+		method  string
+		ctype   string
+		bodies  []string
+		delays  []time.Duration
+		opts    []CallOption
+		out     tprofile
+		err     bool
+		snip    string
+		attempt int
+		delay   time.Duration
+	}{
+		"should decode successful json response": {
+			method: http.MethodGet,
+			ctype:  "application/json; charset=utf-8",
+			bodies: []string{`{"id":7,"name":"gopher"}`},
+			out:    tprofile{ID: 7, Name: "gopher"},
+		},
+		"should decode successful json post response": {
+			method: http.MethodPost,
+			ctype:  "application/problem+json",
+			out:    tprofile{ID: 7, Name: "gopher"},
+		},
+		"should fail on malformed json response": {
+			method: http.MethodGet,
+			ctype:  "application/json",
+			bodies: []string{`{"id":7,"name":`},
+			err:    true,
+			snip:   `{"id":7,"name":`,
+		},
+		"should fail on unexpected content type": {
+			method: http.MethodGet,
+			ctype:  "text/html",
+			bodies: []string{`<html></html>`},
+			err:    true,
+			snip:   `<html></html>`,
+		},
+		"should fail on oversized json response": {
+			method: http.MethodGet,
+			ctype:  "application/json",
+			bodies: []string{`"` + strings.Repeat("a", MaxJSONBodySize) + `"`},
+			err:    true,
+			snip:   `"` + strings.Repeat("a", jsonSnippetSize-1),
+		},
+		"should report hedged call of failed json response": {
+			method:  http.MethodGet,
+			ctype:   "text/html",
+			bodies:  []string{`slow`, `fast`},
+			delays:  []time.Duration{ms_100, ms_0},
+			err:     true,
+			snip:    `fast`,
+			attempt: 1,
+		},
+		"should return hedged json response when hedge wins": {
+			method: http.MethodGet,
+			ctype:  "application/json",
+			bodies: []string{`{"id":1,"name":"slow"}`, `{"id":2,"name":"fast"}`},
+			delays: []time.Duration{ms_100, ms_0},
+			out:    tprofile{ID: 2, Name: "fast"},
+			delay:  ms_50,
+		},
+		"should return primary json response when hedging is disabled for the call": {
+			method: http.MethodGet,
+			ctype:  "application/json",
+			bodies: []string{`{"id":1,"name":"slow"}`, `{"id":2,"name":"fast"}`},
+			delays: []time.Duration{ms_20, ms_0},
+			opts:   []CallOption{CallWithoutHedging()},
+			out:    tprofile{ID: 1, Name: "slow"},
+		},
+		"should return primary json response when call delay is overridden": {
+			method: http.MethodGet,
+			ctype:  "application/json",
+			bodies: []string{`{"id":1,"name":"slow"}`, `{"id":2,"name":"fast"}`},
+			delays: []time.Duration{ms_20, ms_0},
+			opts:   []CallOption{CallWithDelay(ms_100)},
+			out:    tprofile{ID: 1, Name: "slow"},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			cli := NewHTTPClient(
+				&http.Client{},
+				ClientWithCalls(1),
+				ClientWithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_1, http.StatusOK)),
+			)
+			uri, stop := tjsonserv(tcase.ctype, tcase.bodies, tcase.delays)
+			defer stop()
+			var out tprofile
+			var err error
+			ts := time.Now()
+			if tcase.method == http.MethodPost {
+				out, err = PostJSON[tprofile](context.TODO(), cli, uri, tprofile{ID: 7, Name: "gopher"}, tcase.opts...)
+			} else {
+				out, err = GetJSON[tprofile](context.TODO(), cli, uri, tcase.opts...)
+			}
+			ds := time.Since(ts)
+			if tcase.err != (err != nil) {
+				t.Fatalf("expected err presence %t but got %v", tcase.err, err)
+			}
+			if err != nil {
+				var cerr ErrJSONCall
+				if !errors.As(err, &cerr) {
+					t.Fatalf("expected err to be json call error but got %v", err)
+				}
+				if cerr.Snippet != tcase.snip {
+					t.Fatalf("expected err snippet %q but got %q", tcase.snip, cerr.Snippet)
+				}
+				if cerr.Attempt != tcase.attempt || cerr.Hedged != (tcase.attempt > 0) {
+					t.Fatalf("expected err attempt %d but got %d hedged %t", tcase.attempt, cerr.Attempt, cerr.Hedged)
+				}
+			}
+			if out != tcase.out {
+				t.Fatalf("expected decoded value %v but got %v", tcase.out, out)
+			}
+			if tcase.delay != 0 && tcase.delay < ds {
+				t.Fatalf("expected response latency be < %s but got %s", tcase.delay, ds)
+			}
+		})
+	}
+}
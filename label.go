@@ -0,0 +1,47 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// labelID defines path segment placeholder for segments that look like identifiers.
+const labelID = ":id"
+
+var labelIDs = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// WithLabelSanitizer sets hedged transport label sanitizer that converts requests to labels
+// used in observer events and reports instead of raw request urls.
+// Provided sanitizer receives shallow copy of the request without body.
+// By default requests are labeled by matched resource name or url pattern,
+// otherwise by request host and path with identifier looking path segments collapsed and without query.
+func WithLabelSanitizer(sanitizer func(*http.Request) string) TransportOption {
+	return func(t *transport) {
+		t.sanitizer = sanitizer
+	}
+}
+
+func (t *transport) label(req *http.Request, rs Resource) string {
+	if t.sanitizer != nil {
+		r := *req
+		r.Body, r.GetBody = nil, nil
+		return t.sanitizer(&r)
+	}
+	if d, ok := rs.(*decorated); ok && d.name != "" {
+		return d.name
+	}
+	if st, ok := staticOf(rs); ok && st.url != nil && st.url.String() != "" {
+		return st.method + " " + st.url.String()
+	}
+	if req.URL == nil {
+		return req.Method
+	}
+	segments := strings.Split(req.URL.EscapedPath(), "/")
+	for i, s := range segments {
+		if labelIDs.MatchString(s) {
+			segments[i] = labelID
+		}
+	}
+	return req.Method + " " + req.URL.Host + strings.Join(segments, "/")
+}
@@ -0,0 +1,102 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTransportLabel(t *testing.T) {
+	ttable := map[string]struct {
+		url   string
+		rs    Resource
+		label string
+	}{
+		"should label unmatched request by host and path": {
+			url:   "http://example.com/v1/users",
+			label: "GET example.com/v1/users",
+		},
+		"should collapse numeric path segments": {
+			url:   "http://example.com/v1/users/42/orders/7",
+			label: "GET example.com/v1/users/:id/orders/:id",
+		},
+		"should collapse uuid and hex path segments": {
+			url:   "http://example.com/v1/users/123e4567-e89b-12d3-a456-426614174000/tokens/0123456789abcdef0123",
+			label: "GET example.com/v1/users/:id/tokens/:id",
+		},
+		"should drop query string": {
+			url:   "http://example.com/v1/users/42?email=john@example.com&token=secret",
+			label: "GET example.com/v1/users/:id",
+		},
+		"should normalize url": {
+			url:   "http://EXAMPLE.com:80",
+			label: "GET example.com/",
+		},
+		"should label request by matched resource pattern": {
+			url:   "http://example.com/v1/users/42?token=secret",
+			rs:    NewResourceStatic(http.MethodGet, regexp.MustCompile(`/v1/users/[0-9]+`), ms_0, http.StatusOK),
+			label: "GET /v1/users/[0-9]+",
+		},
+		"should label request by matched resource name": {
+			url:   "http://example.com/v1/users/42?token=secret",
+			rs:    NewResourceWithOptions(NewResourceStatic(http.MethodGet, regexp.MustCompile(`users`), ms_0, http.StatusOK), ResourceWithName("users")),
+			label: "users",
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var lock sync.Mutex
+			labels := make(map[string]bool)
+			obs := ObserverFunc(func(e Event) {
+				lock.Lock()
+				defer lock.Unlock()
+				labels[e.Label] = true
+			})
+			opts := []TransportOption{WithTransportObserver(obs)}
+			if tcase.rs != nil {
+				opts = append(opts, WithResources(tcase.rs))
+			}
+			rt := NewTransport(newRecorder(ms_0), opts...)
+			req, _ := http.NewRequest(http.MethodGet, tcase.url, nil)
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if len(labels) != 1 || !labels[tcase.label] {
+				t.Fatalf("expected only label %q but got %v", tcase.label, labels)
+			}
+		})
+	}
+}
+
+func TestWithLabelSanitizer(t *testing.T) {
+	var labels []string
+	obs := ObserverFunc(func(e Event) {
+		if e.Kind == EventMatch {
+			labels = append(labels, e.Label)
+		}
+	})
+	rt := NewTransport(
+		newRecorder(ms_0),
+		WithTransportObserver(obs),
+		WithLabelSanitizer(func(req *http.Request) string {
+			if req.Body != nil || req.GetBody != nil {
+				t.Fatal("expected sanitizer not to see request body")
+			}
+			return req.Method + " " + req.URL.Path
+		}),
+	)
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/users?token=secret", strings.NewReader("secret"))
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	if len(labels) != 1 || labels[0] != "POST /users" {
+		t.Fatalf("expected label %q but got %v", "POST /users", labels)
+	}
+	if req.Body == nil {
+		t.Fatal("expected request body to be kept")
+	}
+}
@@ -0,0 +1,35 @@
+package hedgehog
+
+import "net/http"
+
+// WithMaxHedgeContentLength sets hedged transport content length limit above which requests are not hedged,
+// so very large uploads and downloads are never duplicated. Matched requests with body larger than the limit
+// make no hedged calls, unlike `WithMaxBodySize` their responses are still checked and still feed the resource hooks.
+// Once the original call response with body larger than the limit is received, it's kept right away
+// and no more hedged calls are launched, even if some hedged call response is already held.
+// Unknown content lengths, e.g. chunked bodies, are hedged only if hedge unknown is set.
+// Non positive limit means no limit.
+func WithMaxHedgeContentLength(n int64, hedgeUnknown bool) TransportOption {
+	return func(t *transport) {
+		t.maxLength, t.hedgeUnknown = n, hedgeUnknown
+	}
+}
+
+// oversized returns whether provided content length is above transport content length limit, -1 means unknown length.
+func (t *transport) oversized(length int64) bool {
+	if t.maxLength <= 0 {
+		return false
+	}
+	if length < 0 {
+		return !t.hedgeUnknown
+	}
+	return length > t.maxLength
+}
+
+// requestLength returns provided client request content length, -1 means unknown length.
+func requestLength(req *http.Request) int64 {
+	if req.ContentLength == 0 && req.Body != nil && req.Body != http.NoBody {
+		return -1
+	}
+	return req.ContentLength
+}
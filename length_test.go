@@ -0,0 +1,147 @@
+package hedgehog
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMaxHedgeContentLength(t *testing.T) {
+	ttable := map[string]struct {
+		body         func() io.Reader
+		hedgeUnknown bool
+		calls        int64
+	}{
+		"should hedge request with body within the limit": {
+			body:  func() io.Reader { return strings.NewReader(strings.Repeat("a", 100)) },
+			calls: 2,
+		},
+		"should not hedge request with body above the limit": {
+			body:  func() io.Reader { return strings.NewReader(strings.Repeat("a", 2048)) },
+			calls: 1,
+		},
+		"should not hedge request with body of unknown length by default": {
+			body:  func() io.Reader { return io.NopCloser(strings.NewReader(strings.Repeat("a", 100))) },
+			calls: 1,
+		},
+		"should hedge request with body of unknown length if allowed": {
+			body:         func() io.Reader { return io.NopCloser(strings.NewReader(strings.Repeat("a", 100))) },
+			hedgeUnknown: true,
+			calls:        2,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			var calls int64
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt64(&calls, 1)
+				_, _ = io.Copy(io.Discard, req.Body)
+				select {
+				case <-time.After(ms_10):
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			})
+			rt := NewTransport(
+				internal,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodPut, regexp.MustCompile(`upload`), ms_1, http.StatusOK)),
+				WithMaxHedgeContentLength(1024, tcase.hedgeUnknown),
+				WithBodyBuffering(4096),
+			)
+			req, _ := http.NewRequest(http.MethodPut, "http://example.com/upload", tcase.body())
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			if c := atomic.LoadInt64(&calls); c != tcase.calls {
+				t.Fatalf("expected %d upstream calls but got %d", tcase.calls, c)
+			}
+		})
+	}
+	t.Run("should keep oversized original call response right away", func(t *testing.T) {
+		var downloads int64
+		internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			wait := ms_20
+			if attempt, _ := AttemptFromContext(req.Context()); attempt > 0 {
+				wait = ms_50
+			}
+			select {
+			case <-time.After(wait):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			atomic.AddInt64(&downloads, 1)
+			body := strings.NewReader(strings.Repeat("b", 4096))
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(body), ContentLength: 4096, Request: req}, nil
+		})
+		rs := NewResourceWithOptions(
+			NewResourceStatic(http.MethodGet, regexp.MustCompile(`artifact`), ms_1, http.StatusOK),
+			ResourceWithDivergenceCheck(ms_100, nil, nil),
+		)
+		rt := NewTransport(internal, WithCalls(1), WithResources(rs), WithMaxHedgeContentLength(1024, false))
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/artifact", nil)
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+		_ = resp.Body.Close()
+		if elapsed := time.Since(start); elapsed > ms_50 {
+			t.Fatalf("expected original call response within %v but took %v", ms_50, elapsed)
+		}
+		// give the canceled hedged call time to finish.
+		time.Sleep(ms_50)
+		if d := atomic.LoadInt64(&downloads); d != 1 {
+			t.Fatalf("expected exactly 1 download but got %d", d)
+		}
+	})
+	t.Run("should keep readable oversized original call response over held verified hedged call response", func(t *testing.T) {
+		var closed int64
+		internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempt, _ := AttemptFromContext(req.Context())
+			wait, body := ms_20, strings.Repeat("a", 4096)
+			if attempt > 0 {
+				wait, body = ms_5, strings.Repeat("b", 4096)
+			}
+			select {
+			case <-time.After(wait):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), ContentLength: 4096, Request: req}
+			if attempt == 0 {
+				resp.Body = tclosed{Reader: strings.NewReader(body), closed: &closed}
+			}
+			return resp, nil
+		})
+		rs := NewResourceWithOptions(
+			NewResourceStatic(http.MethodGet, regexp.MustCompile(`artifact`), ms_1, http.StatusOK),
+			ResourceWithDivergenceCheck(ms_100, nil, nil),
+		)
+		rt := NewTransport(internal, WithCalls(1), WithResources(rs), WithMaxHedgeContentLength(1024, false))
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/artifact", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+		defer resp.Body.Close()
+		if c := atomic.LoadInt64(&closed); c != 0 {
+			t.Fatalf("expected original call response body to be open but it was closed %d times", c)
+		}
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("expected nil read err but got %v", err)
+		}
+		if string(b) != strings.Repeat("a", 4096) {
+			t.Fatalf("expected original call response body but got %d bytes of %q", len(b), string(b[:1]))
+		}
+	})
+}
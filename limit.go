@@ -0,0 +1,140 @@
+package hedgehog
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// WithMaxConcurrentHedges sets hedged transport limit of concurrent hedged calls for all matched requests,
+// so traffic spikes can't make the transport spawn unbounded number of hedged calls.
+// Original calls never count against the limit, hedged calls over the limit are skipped instead of waiting for free slot.
+// Non positive limit means no limit.
+func WithMaxConcurrentHedges(n int) TransportOption {
+	return func(t *transport) {
+		if n <= 0 {
+			t.slots = nil
+			return
+		}
+		t.slots = make(chan struct{}, n)
+	}
+}
+
+// HedgeLimiter defines abstract hedged calls rate limiter, e.g. `*rate.Limiter`.
+type HedgeLimiter interface {
+	Allow() bool
+}
+
+// WithHedgeLimiter sets hedged transport rate limiter that paces hedged calls of all matched requests,
+// the limiter is consulted right before each hedged call once every other hedged calls limit allows it
+// and hedged calls it denies are skipped,
+// so request with multiple hedged calls may consume multiple limiter permits. Original calls are never limited.
+func WithHedgeLimiter(l HedgeLimiter) TransportOption {
+	return func(t *transport) {
+		t.limiter = l
+	}
+}
+
+type shedder struct {
+	allowed func() bool
+	shed    uint64
+}
+
+// WithLoadShedder sets hedged transport load shedder that is consulted right before each hedged call is launched,
+// hedged calls are skipped while it returns false, e.g. when the process is starved for cpu or connections,
+// as launching extra calls under local pressure only makes things worse. Original calls are never shed.
+// The shedder is called on the hot path concurrently, so it must be cheap and safe for concurrent use,
+// e.g. goroutines number or connection pool utilization check. Shed hedged calls are reported in transport stats.
+func WithLoadShedder(allowed func() bool) TransportOption {
+	return func(t *transport) {
+		if allowed == nil {
+			t.shedder = nil
+			return
+		}
+		t.shedder = &shedder{allowed: allowed}
+	}
+}
+
+// allow returns whether hedged call can be launched, nil shedder never sheds.
+func (s *shedder) allow() bool {
+	if s == nil || s.allowed() {
+		return true
+	}
+	atomic.AddUint64(&s.shed, 1)
+	return false
+}
+
+// count returns number of shed hedged calls, nil shedder has none.
+func (s *shedder) count() uint64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&s.shed)
+}
+
+type hosts struct {
+	max    int
+	lock   sync.Mutex
+	active map[string]int
+}
+
+// WithMaxConcurrentHedgesPerHost sets hedged transport limit of concurrent hedged calls per request url host,
+// so single slow host can't consume hedged calls capacity of other hosts. Original calls never count against the limit,
+// hedged calls over the limit are skipped instead of waiting for free slot. Hosts are tracked only while they have
+// hedged calls in flight, so idle hosts take no memory. Non positive limit means no limit.
+func WithMaxConcurrentHedgesPerHost(n int) TransportOption {
+	return func(t *transport) {
+		if n <= 0 {
+			t.hosts = nil
+			return
+		}
+		t.hosts = &hosts{max: n, active: make(map[string]int)}
+	}
+}
+
+// acquire tries to reserve one hedged call slot for provided host, nil hosts are never limited.
+func (hs *hosts) acquire(host string) bool {
+	if hs == nil {
+		return true
+	}
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	if hs.active[host] >= hs.max {
+		return false
+	}
+	hs.active[host]++
+	return true
+}
+
+// release frees one hedged call slot previously reserved by acquire and evicts the host once it's idle.
+func (hs *hosts) release(host string) {
+	if hs == nil {
+		return
+	}
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	if hs.active[host]--; hs.active[host] <= 0 {
+		delete(hs.active, host)
+	}
+}
+
+// reserve tries to reserve transport hedged call slot for provided request and returns its release function.
+func (t *transport) reserve(req *http.Request) (release func(), ok bool) {
+	host := req.URL.Host
+	if !t.hosts.acquire(host) {
+		return nil, false
+	}
+	if t.slots == nil {
+		return func() { t.hosts.release(host) }, true
+	}
+	select {
+	case t.slots <- struct{}{}:
+		return func() {
+			<-t.slots
+			t.hosts.release(host)
+		}, true
+	default:
+		t.hosts.release(host)
+		return nil, false
+	}
+}
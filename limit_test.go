@@ -0,0 +1,305 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMaxConcurrentHedges(t *testing.T) {
+	const requests = 100
+	var skipped int64
+	obs := ObserverFunc(func(e Event) {
+		if e.Kind == EventHedgeSkipped {
+			atomic.AddInt64(&skipped, 1)
+		}
+	})
+	rec := newRecorder(ms_50)
+	rt := NewTransport(
+		rec,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK)),
+		WithMaxConcurrentHedges(5),
+		WithTransportObserver(obs),
+	)
+	var wg sync.WaitGroup
+	var failed int64
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			if _, err := rt.RoundTrip(req); err != nil {
+				atomic.AddInt64(&failed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if failed != 0 {
+		t.Fatalf("expected all requests to succeed but got %d failures", failed)
+	}
+	rec.lock.Lock()
+	calls := rec.calls["/search"]
+	rec.lock.Unlock()
+	if calls <= requests || calls > requests+5 {
+		t.Fatalf("expected upstream calls be within (%d, %d] but got %d", requests, requests+5, calls)
+	}
+	if s := atomic.LoadInt64(&skipped); int(s) != 2*requests-calls {
+		t.Fatalf("expected %d skipped hedged calls but got %d", 2*requests-calls, s)
+	}
+}
+
+func TestWithMaxConcurrentHedgesPerHost(t *testing.T) {
+	const requests = 20
+	slow, fast := newRecorder(ms_100), newRecorder(ms_10)
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "slow.example.com" {
+			return slow.RoundTrip(req)
+		}
+		return fast.RoundTrip(req)
+	})
+	rt := NewTransport(
+		internal,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK)),
+		WithMaxConcurrentHedgesPerHost(2),
+	)
+	call := func(host string) error {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+host+"/search", nil)
+		_, err := rt.RoundTrip(req)
+		return err
+	}
+	var wg sync.WaitGroup
+	var failed int64
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := call("slow.example.com"); err != nil {
+				atomic.AddInt64(&failed, 1)
+			}
+		}()
+	}
+	// saturated slow host doesn't reduce hedging on fast host.
+	time.Sleep(ms_10)
+	for i := 0; i < 5; i++ {
+		if err := call("fast.example.com"); err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+	}
+	wg.Wait()
+	if failed != 0 {
+		t.Fatalf("expected all requests to succeed but got %d failures", failed)
+	}
+	if calls := slow.calls["/search"]; calls <= requests || calls > requests+2 {
+		t.Fatalf("expected slow host upstream calls be within (%d, %d] but got %d", requests, requests+2, calls)
+	}
+	if calls := fast.calls["/search"]; calls != 10 {
+		t.Fatalf("expected fast host upstream calls be %d but got %d", 10, calls)
+	}
+	// idle hosts are evicted.
+	for i := 0; i < 100 && len(hostsOf(rt)) > 0; i++ {
+		time.Sleep(ms_1)
+	}
+	if active := hostsOf(rt); len(active) != 0 {
+		t.Fatalf("expected no tracked hosts but got %v", active)
+	}
+}
+
+func hostsOf(rt http.RoundTripper) map[string]int {
+	hs := rt.(*transport).hosts
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	active := make(map[string]int, len(hs.active))
+	for host, n := range hs.active {
+		active[host] = n
+	}
+	return active
+}
+
+type tlimiter struct {
+	permits int64
+	calls   int64
+}
+
+func (l *tlimiter) Allow() bool {
+	atomic.AddInt64(&l.calls, 1)
+	return atomic.AddInt64(&l.permits, -1) >= 0
+}
+
+func TestWithHedgeLimiter(t *testing.T) {
+	const requests = 3
+	var skipped, primaries int64
+	obs := ObserverFunc(func(e Event) {
+		if e.Kind == EventHedgeSkipped {
+			atomic.AddInt64(&skipped, 1)
+		}
+	})
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if attempt, _ := AttemptFromContext(req.Context()); attempt == 0 {
+			atomic.AddInt64(&primaries, 1)
+			time.Sleep(ms_5)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		}
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	limiter := &tlimiter{permits: 4}
+	rt := NewTransport(
+		internal,
+		WithCalls(3),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_0, http.StatusOK)),
+		WithHedgeLimiter(limiter),
+		WithTransportObserver(obs),
+	)
+	for i := 0; i < requests; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+		_ = resp.Body.Close()
+		// original calls are never throttled.
+		if elapsed := time.Since(start); elapsed > ms_50 {
+			t.Fatalf("expected original call to take less than %v but took %v", ms_50, elapsed)
+		}
+	}
+	if p := atomic.LoadInt64(&primaries); p != requests {
+		t.Fatalf("expected %d original calls but got %d", requests, p)
+	}
+	if c := atomic.LoadInt64(&limiter.calls); c != requests*3 {
+		t.Fatalf("expected limiter to be consulted %d times but got %d", requests*3, c)
+	}
+	if s := atomic.LoadInt64(&skipped); s != requests*3-4 {
+		t.Fatalf("expected %d skipped hedged calls but got %d", requests*3-4, s)
+	}
+}
+
+func TestWithLoadShedder(t *testing.T) {
+	var primaries, hedges int64
+	var pressure int32
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if attempt, _ := AttemptFromContext(req.Context()); attempt == 0 {
+			atomic.AddInt64(&primaries, 1)
+			time.Sleep(ms_5)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		}
+		atomic.AddInt64(&hedges, 1)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	rt := NewTransport(
+		internal,
+		WithCalls(2),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_0, http.StatusOK)),
+		WithLoadShedder(func() bool { return atomic.LoadInt32(&pressure) == 0 }),
+	)
+	burst := func() (int64, int64) {
+		atomic.StoreInt64(&primaries, 0)
+		atomic.StoreInt64(&hedges, 0)
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+		}
+		return atomic.LoadInt64(&primaries), atomic.LoadInt64(&hedges)
+	}
+	if p, h := burst(); p != 3 || h != 6 {
+		t.Fatalf("expected 3 original and 6 hedged calls without pressure but got %d and %d", p, h)
+	}
+	atomic.StoreInt32(&pressure, 1)
+	if p, h := burst(); p != 3 || h != 0 {
+		t.Fatalf("expected 3 original and 0 hedged calls under pressure but got %d and %d", p, h)
+	}
+	if stats, _ := GetStats(rt); stats.Shed != 6 {
+		t.Fatalf("expected 6 shed hedged calls but got %d", stats.Shed)
+	}
+	atomic.StoreInt32(&pressure, 0)
+	if p, h := burst(); p != 3 || h != 6 {
+		t.Fatalf("expected 3 original and 6 hedged calls once pressure is gone but got %d and %d", p, h)
+	}
+}
+
+func TestHedgeSkipRefunds(t *testing.T) {
+	ttable := map[string]struct {
+		maxConcurrent int
+		tokens        float64
+		permits       int64
+		limiterCalls  int64
+		tokensLeft    float64
+		budgetHedges  uint64
+	}{
+		"should debit neither throttle nor limiter for hedged calls skipped by resource cap": {
+			maxConcurrent: 1,
+			tokens:        10,
+			permits:       10,
+			limiterCalls:  1,
+			tokensLeft:    9,
+			budgetHedges:  1,
+		},
+		"should refund resource budget for hedged calls skipped by throttle": {
+			tokens:       1,
+			permits:      10,
+			limiterCalls: 1,
+			tokensLeft:   0,
+			budgetHedges: 1,
+		},
+		"should refund throttle and resource budget for hedged calls skipped by limiter": {
+			tokens:       10,
+			permits:      1,
+			limiterCalls: 3,
+			tokensLeft:   9,
+			budgetHedges: 1,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if attempt, _ := AttemptFromContext(req.Context()); attempt == 0 {
+					time.Sleep(ms_10)
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+				}
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			})
+			budget := NewHedgeBudget("search", 10, time.Minute)
+			rs := NewResourceWithOptions(
+				NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK),
+				ResourceWithMaxConcurrent(tcase.maxConcurrent),
+				ResourceWithBudget(budget),
+			)
+			limiter := &tlimiter{permits: tcase.permits}
+			rt := NewTransport(
+				internal,
+				WithCalls(3),
+				WithResources(rs),
+				WithHedgeThrottling(tcase.tokens, 0),
+				WithHedgeLimiter(limiter),
+			)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			if c := atomic.LoadInt64(&limiter.calls); c != tcase.limiterCalls {
+				t.Fatalf("expected limiter to be consulted %d times but got %d", tcase.limiterCalls, c)
+			}
+			stats, _ := GetStats(rt)
+			if tokens := stats.Throttle.Tokens; tokens != tcase.tokensLeft {
+				t.Fatalf("expected %v throttle tokens left but got %v", tcase.tokensLeft, tokens)
+			}
+			if hedges := stats.Budgets["search"].Hedges; hedges != tcase.budgetHedges {
+				t.Fatalf("expected %d budget hedged calls but got %d", tcase.budgetHedges, hedges)
+			}
+		})
+	}
+}
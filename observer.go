@@ -0,0 +1,139 @@
+package hedgehog
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// EventKind defines hedged transport lifecycle event kind.
+type EventKind int
+
+// Hedged transport lifecycle event kinds.
+const (
+	// EventMatch is emitted once resources matching is done, its resource is nil if no resource matched.
+	EventMatch EventKind = iota
+	// EventDelay is emitted once hedged calls delay is computed.
+	EventDelay
+	// EventAttemptStart is emitted right before each http call starts.
+	EventAttemptStart
+	// EventAttemptEnd is emitted right after each http call ends and is checked.
+	EventAttemptEnd
+	// EventHedgeSkipped is emitted for each hedged call that was skipped.
+	EventHedgeSkipped
+	// EventWinner is emitted once successful http response is chosen.
+	EventWinner
+	// EventFailure is emitted once all http calls failed.
+	EventFailure
+	// EventStale is emitted once stale cached http response is returned instead of failure.
+	EventStale
+	// EventCheckViolation is emitted for each http response that failed soft resource check.
+	EventCheckViolation
+	// EventBypass is emitted once matched http request is not hedged as its body couldn't be replayed or is too large
+	// or as it takes over the connection or awaits continue response.
+	EventBypass
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventMatch:
+		return "match"
+	case EventDelay:
+		return "delay"
+	case EventAttemptStart:
+		return "attempt_start"
+	case EventAttemptEnd:
+		return "attempt_end"
+	case EventHedgeSkipped:
+		return "hedge_skipped"
+	case EventWinner:
+		return "winner"
+	case EventFailure:
+		return "failure"
+	case EventStale:
+		return "stale"
+	case EventCheckViolation:
+		return "check_violation"
+	case EventBypass:
+		return "bypass"
+	default:
+		return "unknown"
+	}
+}
+
+// Event defines hedged transport lifecycle event,
+// attempt index is 0 for original http call and 1..N for hedged calls,
+// label is the request label produced by hedged transport label sanitizer,
+// failover is only set for failover http call events, shadow is only set for shadow hedged calls events, see `WithShadowMode`,
+// tie is only set for winner events of original calls preferred over hedged calls, see `WithPrimaryPreference`,
+// cohort is only set for requests of transport with A/B experiment, see `WithExperiment`.
+type Event struct {
+	Kind       EventKind
+	Request    *http.Request
+	Label      string
+	Resource   Resource
+	Attempt    int
+	Delay      time.Duration
+	Elapsed    time.Duration
+	StatusCode int
+	Err        error
+	Failover   bool
+	Shadow     bool
+	Tie        bool
+	Cohort     Cohort
+}
+
+// Observer defines hedged transport lifecycle events observer.
+// Observers might be called concurrently from multiple goroutines and should not block.
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc defines functional observer adapter.
+type ObserverFunc func(Event)
+
+// Observe calls the function with provided event.
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// WithObserver returns context that attaches provided observer to the request,
+// the observer receives all lifecycle events of the request alongside transport wide observer.
+func WithObserver(ctx context.Context, obs Observer) context.Context {
+	o := overrideFrom(ctx)
+	o.observer = obs
+	return withOverride(ctx, o)
+}
+
+// WithTransportObserver sets hedged transport wide observer that receives lifecycle events of all requests.
+func WithTransportObserver(obs Observer) TransportOption {
+	return func(t *transport) {
+		t.observer = obs
+	}
+}
+
+type observers struct {
+	list   [2]Observer
+	label  string
+	cohort Cohort
+}
+
+func (obs observers) enabled() bool {
+	return obs.list[0] != nil || obs.list[1] != nil
+}
+
+func (obs observers) emit(e Event) {
+	e.Label, e.Cohort = obs.label, obs.cohort
+	for _, o := range obs.list {
+		if o != nil {
+			observe(o, e)
+		}
+	}
+}
+
+func observe(o Observer, e Event) {
+	defer func() {
+		_ = recover()
+	}()
+	o.Observe(e)
+}
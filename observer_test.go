@@ -0,0 +1,137 @@
+package hedgehog
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+type tobserver struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (o *tobserver) Observe(e Event) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	var ev string
+	switch e.Kind {
+	case EventMatch:
+		ev = e.Kind.String()
+		if e.Resource == nil {
+			ev += ":none"
+		}
+	case EventDelay:
+		ev = e.Kind.String() + ":" + e.Delay.String()
+	case EventAttemptStart, EventHedgeSkipped:
+		ev = e.Kind.String() + ":" + string(rune('0'+e.Attempt))
+	case EventAttemptEnd, EventWinner:
+		ev = e.Kind.String() + ":" + string(rune('0'+e.Attempt)) + ":" + http.StatusText(e.StatusCode)
+		if e.Err != nil {
+			ev += ":err"
+		}
+	case EventFailure:
+		ev = e.Kind.String() + ":" + unwrapHTTPError(e.Err)
+	}
+	o.events = append(o.events, ev)
+}
+
+func (o *tobserver) len() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return len(o.events)
+}
+
+func TestWithObserver(t *testing.T) {
+	ttable := map[string]struct {
+		path   string
+		codes  []int
+		delays []time.Duration
+		calls  uint64
+		max    int
+		events []string
+	}{
+		"should observe unmatched request": {
+			path:   "/users",
+			events: []string{"match:none"},
+		},
+		"should observe hedge wins call": {
+			path:   "/profile",
+			codes:  []int{http.StatusOK, http.StatusOK},
+			delays: []time.Duration{ms_100, ms_0},
+			calls:  1,
+			events: []string{
+				"match",
+				"delay:5ms",
+				"attempt_start:0",
+				"attempt_start:1",
+				"attempt_end:1:OK",
+				"winner:1:OK",
+				// canceled original call ends after the winner is returned.
+				"attempt_end:0::err",
+			},
+		},
+		"should observe skipped hedges and failures": {
+			path:   "/profile",
+			codes:  []int{http.StatusForbidden, http.StatusForbidden},
+			delays: []time.Duration{ms_20, ms_0},
+			calls:  2,
+			max:    1,
+			events: []string{
+				"match",
+				"delay:5ms",
+				"attempt_start:0",
+				"hedge_skipped:2",
+				"attempt_start:1",
+				"attempt_end:1:Forbidden:err",
+				"attempt_end:0:Forbidden:err",
+				"failure:resource check failed: received unexpected response status code 403",
+			},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			uri, stop := tserv(http.MethodGet, tcase.path, tcase.codes, tcase.delays)
+			defer stop()
+			var global int64
+			var lock sync.Mutex
+			rt := NewTransport(
+				http.DefaultTransport,
+				WithCalls(tcase.calls),
+				WithResources(NewResourceWithOptions(
+					NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_5, http.StatusOK),
+					ResourceWithMaxConcurrent(tcase.max),
+				)),
+				WithTransportObserver(ObserverFunc(func(Event) {
+					lock.Lock()
+					global++
+					lock.Unlock()
+					panic("observer panics should be recovered")
+				})),
+			)
+			obs := &tobserver{}
+			req, _ := http.NewRequestWithContext(WithObserver(context.TODO(), obs), http.MethodGet, uri+tcase.path, nil)
+			if resp, err := rt.RoundTrip(req); err == nil {
+				_ = resp.Body.Close()
+			}
+			// wait for remaining attempts to end in background.
+			for i := 0; i < 100 && obs.len() < len(tcase.events); i++ {
+				time.Sleep(ms_1)
+			}
+			obs.lock.Lock()
+			defer obs.lock.Unlock()
+			if !reflect.DeepEqual(tcase.events, obs.events) {
+				t.Fatalf("expected observed events %v but got %v", tcase.events, obs.events)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if global != int64(len(tcase.events)) {
+				t.Fatalf("expected %d globally observed events but got %d", len(tcase.events), global)
+			}
+		})
+	}
+}
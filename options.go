@@ -0,0 +1,266 @@
+package hedgehog
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ResourceOption defines resource decorator option.
+type ResourceOption func(*decorated)
+
+type decorated struct {
+	Resource
+	opts          []ResourceOption
+	name          string
+	maxConcurrent int64
+	concurrent    int64
+	divergence    *divergence
+	probe         *probe
+	slowStart     *slowStart
+	smoothing     *smoothing
+	drift         *drift
+	budget        *Budget
+	softCheck     bool
+	fatalCodes    []int
+	calls         *uint64
+	multiplier    float64
+	violations    uint64
+	cooldown      time.Duration
+	cooled        int64
+	suppressed    uint64
+	rand          Rand
+}
+
+// NewResourceWithOptions returns new resource instance that decorates provided resource with provided options.
+// Returned resource delegates matching, checking, delays and hooks to provided resource,
+// while provided options only affect how hedged transport treats this resource.
+// If provided resource is already decorated, its options are extended with provided options.
+func NewResourceWithOptions(rs Resource, opts ...ResourceOption) Resource {
+	if d, ok := rs.(*decorated); ok {
+		rs = d.Resource
+		opts = append(append([]ResourceOption{}, d.opts...), opts...)
+	}
+	r := &decorated{Resource: rs, opts: opts}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// ResourceWithName sets the resource name that is used to identify the resource in stats and events.
+func ResourceWithName(name string) ResourceOption {
+	return func(r *decorated) {
+		r.name = name
+	}
+}
+
+// ResourceWithMaxConcurrent caps the number of concurrent in flight hedged calls for the resource.
+// The cap is enforced independently from any other transport limits, original http calls are never limited.
+// Hedged calls that would exceed the cap are simply skipped.
+// Non positive cap means no limit.
+func ResourceWithMaxConcurrent(n int) ResourceOption {
+	return func(r *decorated) {
+		r.maxConcurrent = int64(n)
+	}
+}
+
+// ResourceWithSoftCheck makes the resource check failures non fatal.
+// Responses failing the resource check are treated as successful ones, while check violations are counted
+// and reported to observers, it's useful to verify the resource allowed codes before enforcing them.
+func ResourceWithSoftCheck() ResourceOption {
+	return func(r *decorated) {
+		r.softCheck = true
+	}
+}
+
+// ResourceWithCalls overrides transport hedged calls number for the resource requests,
+// resources without it use transport hedged calls number. Server policies and per call overrides still take precedence.
+func ResourceWithCalls(calls uint64) ResourceOption {
+	return func(r *decorated) {
+		r.calls = &calls
+	}
+}
+
+// ResourceWithFatalCodes makes the resource check failures with provided response codes terminal,
+// so such check errors are wrapped with `ErrResourceCheckFatal`, e.g. 401 or 422 responses are identical for each call.
+func ResourceWithFatalCodes(codes ...int) ResourceOption {
+	return func(r *decorated) {
+		r.fatalCodes = append(r.fatalCodes, codes...)
+	}
+}
+
+// ResourceWithDelayMultiplier multiplies the resource learned delay by provided multiplier, e.g. 1.5 x p90 latency,
+// so fewer healthy requests are hedged without changing the resource percentile. Multiplier only applies to
+// dynamically adjusted delay of resources created by this package, initial delay used before enough latencies
+// are learned is never multiplied. Non positive multiplier means no multiplier.
+func ResourceWithDelayMultiplier(k float64) ResourceOption {
+	return func(r *decorated) {
+		r.multiplier = k
+	}
+}
+
+// ResourceWithHedgeCooldown suppresses the resource hedged calls for provided cooldown period after each hedged call,
+// so at most one hedged call is made for the resource per cooldown period regardless of the resource requests rate.
+// Original http calls are never suppressed, suppressed hedged calls are simply skipped and counted.
+// Non positive cooldown means no cooldown.
+func ResourceWithHedgeCooldown(cooldown time.Duration) ResourceOption {
+	return func(r *decorated) {
+		r.cooldown = cooldown
+	}
+}
+
+// cool tries to start new cooldown period for the resource hedged call,
+// returned undo restores the previous cooldown period unless another one has already started.
+func (r *decorated) cool() (undo func(), ok bool) {
+	if r.cooldown <= 0 {
+		return func() {}, true
+	}
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&r.cooled)
+	if (last != 0 && now-last < int64(r.cooldown)) || !atomic.CompareAndSwapInt64(&r.cooled, last, now) {
+		atomic.AddUint64(&r.suppressed, 1)
+		return nil, false
+	}
+	return func() { atomic.CompareAndSwapInt64(&r.cooled, now, last) }, true
+}
+
+func (r *decorated) Check(resp *http.Response) error {
+	err := r.Resource.Check(resp)
+	if err == nil {
+		return nil
+	}
+	for _, code := range r.fatalCodes {
+		if code == resp.StatusCode {
+			return ErrResourceCheckFatal{Err: err}
+		}
+	}
+	return err
+}
+
+func (r *decorated) After() <-chan time.Time {
+	if _, ok := r.Resource.(delayer); ok && (r.slowStart != nil || r.smoothing != nil || r.multiplier > 0) {
+		return time.After(r.duration())
+	}
+	return r.Resource.After()
+}
+
+func (r *decorated) duration() time.Duration {
+	d, ok := r.Resource.(delayer)
+	if !ok {
+		return 0
+	}
+	delay := d.duration()
+	if l, ok := r.Resource.(learner); ok && r.multiplier > 0 && l.learned() {
+		delay = time.Duration(float64(delay) * r.multiplier)
+	}
+	if r.smoothing != nil {
+		delay = r.smoothing.smooth(delay)
+	}
+	if r.slowStart != nil {
+		delay = time.Duration(float64(delay) * r.slowStart.multiplier())
+	}
+	return delay
+}
+
+func (r *decorated) Hook(req *http.Request) func(*http.Response) {
+	h := r.Resource.Hook(req)
+	if r.drift == nil {
+		return h
+	}
+	return func(resp *http.Response) {
+		h(resp)
+		if _, ok := r.Resource.(delayer); ok {
+			r.drift.update(r.name, r.duration())
+		}
+	}
+}
+
+// acquire tries to reserve one hedged call slot for the resource.
+func (r *decorated) acquire() bool {
+	if r.maxConcurrent <= 0 {
+		return true
+	}
+	if atomic.AddInt64(&r.concurrent, 1) > r.maxConcurrent {
+		atomic.AddInt64(&r.concurrent, -1)
+		return false
+	}
+	return true
+}
+
+// release frees one hedged call slot previously reserved by acquire.
+func (r *decorated) release() {
+	if r.maxConcurrent <= 0 {
+		return
+	}
+	atomic.AddInt64(&r.concurrent, -1)
+}
+
+// acquireHedge reserves hedged call slot for any resource, non decorated resources are never limited.
+// Returned release frees the slot once the hedged call is done, while returned abort also refunds the resource budget
+// and the cooldown period if the hedged call is never made.
+func acquireHedge(rs Resource) (release, abort func(), ok bool) {
+	d, ok := rs.(*decorated)
+	if !ok {
+		return func() {}, func() {}, true
+	}
+	if !d.acquire() {
+		return nil, nil, false
+	}
+	if d.budget != nil && !d.budget.Allow() {
+		d.release()
+		return nil, nil, false
+	}
+	// cooldown is started only once every other check allows the hedged call.
+	uncool, ok := d.cool()
+	if !ok {
+		d.budget.refund()
+		d.release()
+		return nil, nil, false
+	}
+	return d.release, func() {
+		uncool()
+		d.budget.refund()
+		d.release()
+	}, true
+}
+
+// callsOf returns hedged calls number for any resource, non decorated resources use provided transport calls number.
+func callsOf(rs Resource, calls uint64) uint64 {
+	if d, ok := rs.(*decorated); ok && d.calls != nil {
+		return *d.calls
+	}
+	return calls
+}
+
+// recordPrimary records original call for any resource.
+func recordPrimary(rs Resource) {
+	if d, ok := rs.(*decorated); ok && d.budget != nil {
+		d.budget.Primary()
+	}
+}
+
+// softFail records check violation for any resource and returns whether it should be ignored,
+// non decorated resources check violations are never ignored.
+func softFail(rs Resource) bool {
+	d, ok := rs.(*decorated)
+	if !ok || !d.softCheck {
+		return false
+	}
+	atomic.AddUint64(&d.violations, 1)
+	return true
+}
+
+// sampleHedge decides whether hedged calls should be made for the resource request,
+// provided random numbers source is used unless the resource has its own one.
+func sampleHedge(rs Resource, rnd Rand) bool {
+	d, ok := rs.(*decorated)
+	if !ok || d.slowStart == nil {
+		return true
+	}
+	if d.rand != nil {
+		rnd = d.rand
+	}
+	p := d.slowStart.probability()
+	return p >= 1.0 || rnd.Float64() < p
+}
@@ -0,0 +1,391 @@
+package hedgehog
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type trecorder struct {
+	delay time.Duration
+	lock  sync.Mutex
+	calls map[string]int
+	cur   map[string]int
+	max   map[string]int
+}
+
+func newRecorder(delay time.Duration) *trecorder {
+	return &trecorder{
+		delay: delay,
+		calls: make(map[string]int),
+		cur:   make(map[string]int),
+		max:   make(map[string]int),
+	}
+}
+
+func (r *trecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := req.URL.Path
+	r.lock.Lock()
+	r.calls[path]++
+	r.cur[path]++
+	if r.cur[path] > r.max[path] {
+		r.max[path] = r.cur[path]
+	}
+	r.lock.Unlock()
+	defer func() {
+		r.lock.Lock()
+		r.cur[path]--
+		r.lock.Unlock()
+	}()
+	select {
+	case <-time.After(r.delay):
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func TestResourceWithMaxConcurrent(t *testing.T) {
+	const requests = 10
+	ttable := map[string]struct {
+		cap   int
+		calls int
+	}{
+		"/search": {cap: 2, calls: requests + 2},
+		"/users":  {cap: 1, calls: requests + 1},
+		"/items":  {cap: 0, calls: requests * 4},
+	}
+	rec := newRecorder(ms_50)
+	var res []Resource
+	for path, tcase := range ttable {
+		res = append(res, NewResourceWithOptions(
+			NewResourceStatic(http.MethodGet, regexp.MustCompile(path), ms_1, http.StatusOK),
+			ResourceWithMaxConcurrent(tcase.cap),
+		))
+	}
+	rt := NewRoundTripper(rec, 3, res...)
+	var wg sync.WaitGroup
+	var failed int64
+	for path := range ttable {
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func(path string) {
+				defer wg.Done()
+				req, _ := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+				if _, err := rt.RoundTrip(req); err != nil {
+					atomic.AddInt64(&failed, 1)
+				}
+			}(path)
+		}
+	}
+	wg.Wait()
+	if failed != 0 {
+		t.Fatalf("expected all requests to succeed but got %d failures", failed)
+	}
+	for path, tcase := range ttable {
+		if tcase.cap > 0 && rec.max[path] > requests+tcase.cap {
+			t.Fatalf("expected resource %s concurrency be <= %d but got %d", path, requests+tcase.cap, rec.max[path])
+		}
+		if rec.calls[path] > tcase.calls {
+			t.Fatalf("expected resource %s calls be <= %d but got %d", path, tcase.calls, rec.calls[path])
+		}
+		if rec.calls[path] <= requests {
+			t.Fatalf("expected resource %s calls be > %d but got %d", path, requests, rec.calls[path])
+		}
+	}
+	// wait for canceled hedged calls to end in background.
+	time.Sleep(ms_50)
+	for _, rs := range res {
+		if c := atomic.LoadInt64(&rs.(*decorated).concurrent); c != 0 {
+			t.Fatalf("expected resource concurrency counter be released but got %d", c)
+		}
+	}
+}
+
+func TestResourceWithSoftCheck(t *testing.T) {
+	ttable := map[string]struct {
+		opts       []ResourceOption
+		err        error
+		violations uint64
+	}{
+		"should fail on unexpected response code without soft check": {
+			err: ErrResourceUnexpectedResponseCode{StatusCode: http.StatusInternalServerError},
+		},
+		"should pass unexpected response code through with soft check": {
+			opts:       []ResourceOption{ResourceWithSoftCheck()},
+			violations: 1,
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var violations []int
+			obs := ObserverFunc(func(e Event) {
+				if e.Kind == EventCheckViolation {
+					violations = append(violations, e.StatusCode)
+				}
+			})
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody, Request: req}, nil
+			})
+			rs := NewResourceWithOptions(
+				NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_10, http.StatusOK),
+				tcase.opts...,
+			)
+			rt := NewTransport(internal, WithResources(rs), WithTransportObserver(obs))
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			resp, err := rt.RoundTrip(req)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if err == nil && resp.StatusCode != http.StatusInternalServerError {
+				t.Fatalf("expected response code %d but got %d", http.StatusInternalServerError, resp.StatusCode)
+			}
+			stats, _ := GetStats(rt)
+			if v := stats.Resources[0].Violations; v != tcase.violations {
+				t.Fatalf("expected %d violations but got %d", tcase.violations, v)
+			}
+			if uint64(len(violations)) != tcase.violations {
+				t.Fatalf("expected %d violation events but got %v", tcase.violations, violations)
+			}
+			for _, code := range violations {
+				if code != http.StatusInternalServerError {
+					t.Fatalf("expected violation event code %d but got %d", http.StatusInternalServerError, code)
+				}
+			}
+		})
+	}
+}
+
+func TestResourceWithFatalCodes(t *testing.T) {
+	ttable := map[string]struct {
+		codes   []int
+		delays  []time.Duration
+		opts    []ResourceOption
+		max     time.Duration
+		calls   int64
+		err     error
+		fatal   bool
+		attempt int
+	}{
+		"should return fatal original call check failure right away without hedged calls": {
+			codes:  []int{http.StatusUnauthorized},
+			delays: []time.Duration{ms_10},
+			opts:   []ResourceOption{ResourceWithFatalCodes(http.StatusUnauthorized, http.StatusUnprocessableEntity)},
+			max:    ms_20,
+			calls:  1,
+			err:    ErrResourceUnexpectedResponseCode{StatusCode: http.StatusUnauthorized},
+			fatal:  true,
+		},
+		"should return fatal hedged call check failure without waiting for original call": {
+			codes:   []int{http.StatusOK, http.StatusUnauthorized},
+			delays:  []time.Duration{ms_100, ms_0},
+			opts:    []ResourceOption{ResourceWithFatalCodes(http.StatusUnauthorized)},
+			max:     ms_50,
+			calls:   2,
+			err:     ErrResourceUnexpectedResponseCode{StatusCode: http.StatusUnauthorized},
+			fatal:   true,
+			attempt: 1,
+		},
+		"should keep waiting for hedged calls on retryable check failure": {
+			codes:  []int{http.StatusUnauthorized, http.StatusOK},
+			delays: []time.Duration{ms_10, ms_0},
+			opts:   []ResourceOption{ResourceWithFatalCodes(http.StatusUnprocessableEntity)},
+			max:    time.Second,
+			calls:  2,
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var calls int64
+			codes, delays := tcase.codes, tcase.delays
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				n := atomic.AddInt64(&calls, 1) - 1
+				select {
+				case <-time.After(delays[n]):
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+				return &http.Response{StatusCode: codes[n], Body: http.NoBody, Request: req}, nil
+			})
+			rs := NewResourceWithOptions(
+				NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_20, http.StatusOK),
+				tcase.opts...,
+			)
+			rt := NewTransport(internal, WithCalls(1), WithResources(rs))
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			if elapsed := time.Since(start); elapsed > tcase.max {
+				t.Fatalf("expected round trip latency under %v but got %v", tcase.max, elapsed)
+			}
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+			var fatal ErrResourceCheckFatal
+			if errors.As(err, &fatal) != tcase.fatal {
+				t.Fatalf("expected fatal err %v but got %v", tcase.fatal, err)
+			}
+			var attempt ErrAttemptFailed
+			if tcase.fatal && (!errors.As(err, &attempt) || attempt.Attempt != tcase.attempt) {
+				t.Fatalf("expected fatal err of attempt %d but got %v", tcase.attempt, err)
+			}
+			// no more hedged calls are launched after the fatal check failure.
+			time.Sleep(ms_20)
+			if c := atomic.LoadInt64(&calls); c != tcase.calls {
+				t.Fatalf("expected exactly %d http calls but got %d", tcase.calls, c)
+			}
+		})
+	}
+}
+
+func TestResourceWithCalls(t *testing.T) {
+	ttable := map[string]struct {
+		opts  []ResourceOption
+		calls int
+	}{
+		"/search": {opts: []ResourceOption{ResourceWithCalls(3)}, calls: 4},
+		"/users":  {opts: []ResourceOption{ResourceWithCalls(0)}, calls: 1},
+		"/items":  {calls: 2},
+	}
+	rec := newRecorder(ms_20)
+	var res []Resource
+	for path, tcase := range ttable {
+		res = append(res, NewResourceWithOptions(
+			NewResourceStatic(http.MethodGet, regexp.MustCompile(path), ms_1, http.StatusOK),
+			tcase.opts...,
+		))
+	}
+	rt := NewRoundTripper(rec, 1, res...)
+	for path := range ttable {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+	}
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	for path, tcase := range ttable {
+		if rec.calls[path] != tcase.calls {
+			t.Fatalf("expected resource %s calls be %d but got %d", path, tcase.calls, rec.calls[path])
+		}
+	}
+}
+
+func TestResourceWithDelayMultiplier(t *testing.T) {
+	ttable := map[string]struct {
+		rs      Resource
+		history int
+		delay   time.Duration
+	}{
+		"should not multiply initial delay of percentiles resource": {
+			rs:      NewResourcePercentiles(http.MethodGet, nil, ms_10, 0.9, 10, http.StatusOK),
+			history: 4,
+			delay:   ms_10,
+		},
+		"should multiply learned delay of percentiles resource": {
+			rs:      NewResourcePercentiles(http.MethodGet, nil, ms_10, 0.9, 10, http.StatusOK),
+			history: 5,
+			delay:   30 * time.Millisecond,
+		},
+		"should multiply learned delay of average resource": {
+			rs:      NewResourceAverage(http.MethodGet, nil, ms_10, 5, http.StatusOK),
+			history: 5,
+			delay:   30 * time.Millisecond,
+		},
+		"should multiply learned delay of resource group": {
+			rs:      NewResourceGroup(NewDelayerAverage(ms_10, 5), nil, NewMatcher(http.MethodGet, nil)),
+			history: 5,
+			delay:   30 * time.Millisecond,
+		},
+		"should not multiply static resource delay": {
+			rs:      NewResourceStatic(http.MethodGet, nil, ms_10, http.StatusOK),
+			history: 5,
+			delay:   ms_10,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			for i := 0; i < tcase.history; i++ {
+				if g, ok := tcase.rs.(*group); ok {
+					g.delayer.(recorder).record(ms_20)
+					continue
+				}
+				tcase.rs.(recorder).record(ms_20)
+			}
+			rs := NewResourceWithOptions(tcase.rs, ResourceWithDelayMultiplier(1.5))
+			if d := rs.(delayer).duration(); d != tcase.delay {
+				t.Fatalf("expected resource delay %v but got %v", tcase.delay, d)
+			}
+			start := time.Now()
+			<-rs.After()
+			if elapsed := time.Since(start); elapsed < tcase.delay || elapsed > tcase.delay+ms_20 {
+				t.Fatalf("expected resource after to fire after %v but got %v", tcase.delay, elapsed)
+			}
+		})
+	}
+}
+
+func TestResourceWithHedgeCooldown(t *testing.T) {
+	const requests = 50
+	rec := newRecorder(ms_20)
+	rs := NewResourceWithOptions(
+		NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK),
+		ResourceWithHedgeCooldown(time.Second),
+	)
+	rt := NewRoundTripper(rec, 1, rs)
+	var wg sync.WaitGroup
+	var failed int64
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			if _, err := rt.RoundTrip(req); err != nil {
+				atomic.AddInt64(&failed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if failed != 0 {
+		t.Fatalf("expected all requests to succeed but got %d failures", failed)
+	}
+	if calls := rec.calls["/search"]; calls != requests+1 {
+		t.Fatalf("expected %d upstream calls but got %d", requests+1, calls)
+	}
+	stats, _ := GetStats(rt)
+	if s := stats.Resources[0].Suppressed; s != requests-1 {
+		t.Fatalf("expected %d suppressed hedged calls but got %d", requests-1, s)
+	}
+}
+
+func TestResourceWithHedgeCooldownBudget(t *testing.T) {
+	rec := newRecorder(ms_20)
+	// the budget denies the first hedged call, which must not start the cooldown period.
+	rs := NewResourceWithOptions(
+		NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK),
+		ResourceWithBudget(NewHedgeBudget("search", 0.5, time.Minute)),
+		ResourceWithHedgeCooldown(time.Minute),
+	)
+	rt := NewRoundTripper(rec, 1, rs)
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+	}
+	if calls := rec.calls["/search"]; calls != 3 {
+		t.Fatalf("expected 3 upstream calls but got %d", calls)
+	}
+	stats, _ := GetStats(rt)
+	if s := stats.Resources[0].Suppressed; s != 0 {
+		t.Fatalf("expected no suppressed hedged calls but got %d", s)
+	}
+}
@@ -0,0 +1,148 @@
+package hedgehog
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type policy struct {
+	header    string
+	now       func() time.Time
+	lock      sync.Mutex
+	hosts     map[string]serverPolicy
+	applied   uint64
+	malformed uint64
+}
+
+// serverPolicy defines single host hedging policy advertised by the server,
+// zero delay means no delay override and nil calls means no calls override.
+type serverPolicy struct {
+	delay   time.Duration
+	calls   *uint64
+	expires time.Time
+}
+
+// WithServerPolicy enables server driven hedging policy, so servers could advertise their preferred hedging parameters
+// via provided response header, e.g. `Hedgehog-Policy: delay=80ms; max=1; ttl=300`.
+// Policy directive is parsed from successful hedged responses and is kept per request host for its ttl in seconds,
+// while it's valid it's preferred over the matched resource delay, while its max could only lower configured hedged calls number,
+// per call overrides still take precedence over it. Unknown directive keys are ignored,
+// malformed directives are ignored and counted in stats.
+func WithServerPolicy(header string) TransportOption {
+	return withServerPolicy(header, time.Now)
+}
+
+func withServerPolicy(header string, now func() time.Time) TransportOption {
+	return func(t *transport) {
+		t.policy = &policy{
+			header: http.CanonicalHeaderKey(header),
+			now:    now,
+			hosts:  make(map[string]serverPolicy),
+		}
+	}
+}
+
+// lookup returns provided request host valid server policy, nil policy has no server policies.
+func (p *policy) lookup(req *http.Request) (serverPolicy, bool) {
+	if p == nil {
+		return serverPolicy{}, false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	sp, ok := p.hosts[req.URL.Host]
+	if !ok {
+		return serverPolicy{}, false
+	}
+	if !p.now().Before(sp.expires) {
+		delete(p.hosts, req.URL.Host)
+		return serverPolicy{}, false
+	}
+	atomic.AddUint64(&p.applied, 1)
+	return sp, true
+}
+
+// update stores server policy advertised by provided response for provided request host.
+func (p *policy) update(req *http.Request, resp *http.Response) {
+	if p == nil {
+		return
+	}
+	directive := resp.Header.Get(p.header)
+	if directive == "" {
+		return
+	}
+	sp, ttl, ok := parsePolicy(directive)
+	if !ok {
+		atomic.AddUint64(&p.malformed, 1)
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	sp.expires = p.now().Add(ttl)
+	p.hosts[req.URL.Host] = sp
+}
+
+func (p *policy) stats() PolicyStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := p.now()
+	var active int
+	for _, sp := range p.hosts {
+		if now.Before(sp.expires) {
+			active++
+		}
+	}
+	return PolicyStats{
+		Active:    active,
+		Applied:   atomic.LoadUint64(&p.applied),
+		Malformed: atomic.LoadUint64(&p.malformed),
+	}
+}
+
+// maxPolicyCalls defines max hedged calls number that server policy could advertise, larger numbers are malformed.
+const maxPolicyCalls = 255
+
+// parsePolicy parses provided server policy directive and returns the policy along with its ttl.
+// Directive must contain positive ttl and at least one of delay or max.
+func parsePolicy(directive string) (serverPolicy, time.Duration, bool) {
+	var sp serverPolicy
+	var ttl time.Duration
+	for _, part := range strings.Split(directive, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return serverPolicy{}, 0, false
+		}
+		key, val := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		switch key {
+		case "delay":
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				return serverPolicy{}, 0, false
+			}
+			sp.delay = d
+		case "max":
+			n, err := strconv.ParseUint(val, 10, 64)
+			if err != nil || n > maxPolicyCalls {
+				return serverPolicy{}, 0, false
+			}
+			sp.calls = &n
+		case "ttl":
+			n, err := strconv.ParseUint(val, 10, 32)
+			if err != nil || n == 0 {
+				return serverPolicy{}, 0, false
+			}
+			ttl = time.Duration(n) * time.Second
+		}
+	}
+	if ttl == 0 || (sp.delay == 0 && sp.calls == nil) {
+		return serverPolicy{}, 0, false
+	}
+	return sp, ttl, true
+}
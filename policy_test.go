@@ -0,0 +1,126 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithServerPolicy(t *testing.T) {
+	ttable := map[string]struct {
+		directive string
+		during    int64
+		malformed uint64
+	}{
+		"should obey server policy during its ttl": {
+			directive: "delay=1ms; max=1; ttl=300",
+			during:    2,
+		},
+		"should not raise hedged calls with server policy": {
+			directive: "delay=1ms; max=200; ttl=300",
+			during:    2,
+		},
+		"should obey server policy without hedged calls": {
+			directive: "max=0; ttl=300",
+			during:    1,
+		},
+		"should ignore malformed server policy": {
+			directive: "delay=fast; max=2; ttl=300",
+			during:    2,
+			malformed: 1,
+		},
+		"should ignore overflowing server policy": {
+			directive: "max=18446744073709551615; ttl=300",
+			during:    2,
+			malformed: 1,
+		},
+		"should ignore server policy without ttl": {
+			directive: "delay=1ms; max=2",
+			during:    2,
+			malformed: 1,
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var attempts int64
+			directive := tcase.directive
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt64(&attempts, 1)
+				resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}
+				if req.URL.Path == "/policy" {
+					resp.Header.Set("Hedgehog-Policy", directive)
+					return resp, nil
+				}
+				select {
+				case <-time.After(ms_20):
+					return resp, nil
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			})
+			clock := newClock()
+			rt := NewTransport(
+				internal,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_5, http.StatusOK)),
+				withServerPolicy("hedgehog-policy", clock.now),
+			)
+			roundTrip := func(path string) int64 {
+				atomic.StoreInt64(&attempts, 0)
+				req, _ := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+				if _, err := rt.RoundTrip(req); err != nil {
+					t.Fatalf("expected nil err but got %v", err)
+				}
+				return atomic.LoadInt64(&attempts)
+			}
+			_ = roundTrip("/policy")
+			if a := roundTrip("/search"); a != tcase.during {
+				t.Fatalf("expected %d attempts during policy ttl but got %d", tcase.during, a)
+			}
+			clock.advance(300 * time.Second)
+			if a := roundTrip("/search"); a != 2 {
+				t.Fatalf("expected %d attempts after policy ttl but got %d", 2, a)
+			}
+			stats, _ := GetStats(rt)
+			if stats.Policy.Active != 0 {
+				t.Fatalf("expected no active policies but got %d", stats.Policy.Active)
+			}
+			if stats.Policy.Malformed != tcase.malformed {
+				t.Fatalf("expected %d malformed policies but got %d", tcase.malformed, stats.Policy.Malformed)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	two := uint64(2)
+	ttable := map[string]struct {
+		policy serverPolicy
+		ttl    time.Duration
+		ok     bool
+	}{
+		"delay=80ms; max=2; ttl=300":  {policy: serverPolicy{delay: 80 * time.Millisecond, calls: &two}, ttl: 300 * time.Second, ok: true},
+		"DELAY=80ms;ttl=1;":           {policy: serverPolicy{delay: 80 * time.Millisecond}, ttl: time.Second, ok: true},
+		"delay=80ms; ttl=1; jitter=5": {policy: serverPolicy{delay: 80 * time.Millisecond}, ttl: time.Second, ok: true},
+		"delay=80ms; ttl=0":           {},
+		"delay=-1ms; ttl=1":           {},
+		"max=-1; ttl=1":               {},
+		"max=256; ttl=1":              {},
+		"ttl=1":                       {},
+		"delay; ttl=1":                {},
+		"":                            {},
+	}
+	for directive, tcase := range ttable {
+		t.Run(directive, func(t *testing.T) {
+			policy, ttl, ok := parsePolicy(directive)
+			if ok != tcase.ok || ttl != tcase.ttl || policy.delay != tcase.policy.delay {
+				t.Fatalf("expected policy %v %v %v but got %v %v %v", tcase.policy.delay, tcase.ttl, tcase.ok, policy.delay, ttl, ok)
+			}
+			if (policy.calls == nil) != (tcase.policy.calls == nil) || (policy.calls != nil && *policy.calls != *tcase.policy.calls) {
+				t.Fatalf("expected policy calls %v but got %v", tcase.policy.calls, policy.calls)
+			}
+		})
+	}
+}
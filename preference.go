@@ -0,0 +1,47 @@
+package hedgehog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WithPrimaryPreference makes hedged transport prefer original call response over hedged call response
+// when both succeed within provided epsilon of each other. Once hedged call delivers the first successful response
+// the transport waits up to epsilon for still in flight original call, if it succeeds within that window
+// its response is returned instead, the hedged call response is closed and the outcome is accounted as a tie.
+// Primary preference is not applied to resources with divergence check.
+// Non positive epsilon means no preference.
+func WithPrimaryPreference(epsilon time.Duration) TransportOption {
+	return func(t *transport) {
+		t.preference = epsilon
+	}
+}
+
+type wins struct {
+	primary uint64
+	hedged  uint64
+	ties    uint64
+}
+
+// record accounts provided winner attempt outcome, nil wins account nothing.
+func (w *wins) record(attempt uint64, tie bool) {
+	if w == nil {
+		return
+	}
+	switch {
+	case tie:
+		atomic.AddUint64(&w.ties, 1)
+	case attempt == 0:
+		atomic.AddUint64(&w.primary, 1)
+	default:
+		atomic.AddUint64(&w.hedged, 1)
+	}
+}
+
+func (w *wins) stats() WinStats {
+	return WinStats{
+		Primary: atomic.LoadUint64(&w.primary),
+		Hedged:  atomic.LoadUint64(&w.hedged),
+		Ties:    atomic.LoadUint64(&w.ties),
+	}
+}
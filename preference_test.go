@@ -0,0 +1,138 @@
+package hedgehog
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type tbody struct {
+	closed func()
+}
+
+func (b tbody) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (b tbody) Close() error {
+	b.closed()
+	return nil
+}
+
+func TestWithPrimaryPreference(t *testing.T) {
+	ttable := map[string]struct {
+		epsilon     time.Duration
+		hedgeFirst  bool
+		gap         time.Duration
+		max         time.Duration
+		attempt     string
+		wins        WinStats
+		tie         bool
+		hedgeClosed bool
+	}{
+		"should prefer original call finished right after hedged call": {
+			epsilon:     ms_50,
+			hedgeFirst:  true,
+			attempt:     "0",
+			wins:        WinStats{Ties: 1},
+			tie:         true,
+			hedgeClosed: true,
+		},
+		"should return hedged call once preference window elapses": {
+			epsilon:    ms_10,
+			hedgeFirst: true,
+			gap:        ms_100,
+			max:        ms_50,
+			attempt:    "1",
+			wins:       WinStats{Hedged: 1},
+		},
+		"should return original call finished right before hedged call": {
+			epsilon: ms_50,
+			attempt: "0",
+			wins:    WinStats{Primary: 1},
+		},
+		"should return hedged call finished first without preference": {
+			hedgeFirst: true,
+			attempt:    "1",
+			wins:       WinStats{Hedged: 1},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var calls, closed int64
+			hedgeFirst, gap := tcase.hedgeFirst, ms_1
+			if tcase.gap > 0 {
+				gap = tcase.gap
+			}
+			started, primaryDone, hedgeDone := make(chan struct{}), make(chan struct{}), make(chan struct{})
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempt := atomic.AddInt64(&calls, 1) - 1
+				resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: req}
+				resp.Body = tbody{closed: func() {
+					if attempt == 1 {
+						atomic.AddInt64(&closed, 1)
+					}
+				}}
+				switch {
+				case attempt == 0 && hedgeFirst:
+					<-hedgeDone
+					time.Sleep(gap)
+				case attempt == 0:
+					<-started
+					defer close(primaryDone)
+				case hedgeFirst:
+					defer close(hedgeDone)
+				default:
+					close(started)
+					<-primaryDone
+					time.Sleep(ms_1)
+				}
+				resp.Header.Set("X-Attempt", string(rune('0'+attempt)))
+				return resp, nil
+			})
+			var tie bool
+			obs := ObserverFunc(func(e Event) {
+				if e.Kind == EventWinner {
+					tie = e.Tie
+				}
+			})
+			rt := NewTransport(
+				internal,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+				WithPrimaryPreference(tcase.epsilon),
+				WithTransportObserver(obs),
+			)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			// preference window bounds added latency.
+			if elapsed := time.Since(start); tcase.max > 0 && elapsed > tcase.max {
+				t.Fatalf("expected round trip to take at most %v but took %v", tcase.max, elapsed)
+			}
+			if a := resp.Header.Get("X-Attempt"); a != tcase.attempt {
+				t.Fatalf("expected winner attempt %s but got %s", tcase.attempt, a)
+			}
+			if tie != tcase.tie {
+				t.Fatalf("expected winner tie %v but got %v", tcase.tie, tie)
+			}
+			// held hedged call response is closed by the reaper in background.
+			for i := 0; i < 100 && tcase.hedgeClosed && atomic.LoadInt64(&closed) == 0; i++ {
+				time.Sleep(ms_1)
+			}
+			if c := atomic.LoadInt64(&closed); tcase.hedgeClosed && c != 1 {
+				t.Fatalf("expected held hedged call response to be closed but got %d closes", c)
+			}
+			stats, _ := GetStats(rt)
+			if stats.Wins != tcase.wins {
+				t.Fatalf("expected wins %+v but got %+v", tcase.wins, stats.Wins)
+			}
+		})
+	}
+}
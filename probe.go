@@ -0,0 +1,66 @@
+package hedgehog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrResourceBodyProbe defines resource response check error that is returned when response body prefix fails the resource body probe.
+type ErrResourceBodyProbe struct {
+	Err error
+}
+
+func (err ErrResourceBodyProbe) Error() string {
+	return fmt.Sprintf("resource body probe failed: %v", err.Err)
+}
+
+func (err ErrResourceBodyProbe) Unwrap() error {
+	return err.Err
+}
+
+type probe struct {
+	n      int
+	verify func([]byte) error
+}
+
+// ResourceWithBodyProbe makes hedged transport verify up to the first n bytes of each http call response body
+// that passed the resource check before the call could win, e.g. to reject truncated or html error bodies behind 200 status code.
+// Responses failing provided verify function are treated as failing the resource check with `ErrResourceBodyProbe`,
+// so other calls could still win. Probed bytes are replayed transparently to the caller from the winner body.
+// As the probe reads the body before the call wins, the call latency and resource hooks include the probe read time.
+// Non positive n or nil verify function means no probe.
+func ResourceWithBodyProbe(n int, verify func([]byte) error) ResourceOption {
+	return func(r *decorated) {
+		if n <= 0 || verify == nil {
+			r.probe = nil
+			return
+		}
+		r.probe = &probe{n: n, verify: verify}
+	}
+}
+
+func probeOf(rs Resource) *probe {
+	if d, ok := rs.(*decorated); ok {
+		return d.probe
+	}
+	return nil
+}
+
+// check reads provided response body prefix, replaces response body with the one that replays the prefix
+// and verifies the prefix, nil probe never fails.
+func (p *probe) check(resp *http.Response) error {
+	if p == nil {
+		return nil
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, int64(p.n)))
+	resp.Body = replayBody{Reader: io.MultiReader(bytes.NewReader(b), resp.Body), Closer: resp.Body}
+	if err != nil {
+		return err
+	}
+	if err := p.verify(b); err != nil {
+		return ErrResourceBodyProbe{Err: err}
+	}
+	return nil
+}
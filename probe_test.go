@@ -0,0 +1,76 @@
+package hedgehog
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourceWithBodyProbe(t *testing.T) {
+	verify := func(b []byte) error {
+		if !strings.HasPrefix(string(b), "{") {
+			return errors.New("unexpected non json body")
+		}
+		return nil
+	}
+	ttable := map[string]struct {
+		bodies []string
+		body   string
+		err    error
+	}{
+		"should return original call response with intact body once it passes the probe": {
+			bodies: []string{`{"name":"hedgehog","kind":"animal"}`, `{"name":"hedgehog"}`},
+			body:   `{"name":"hedgehog","kind":"animal"}`,
+		},
+		"should return slower hedged call response once faster original call response fails the probe": {
+			bodies: []string{`<html><body>502 Bad Gateway</body></html>`, `{"name":"hedgehog","kind":"animal"}`},
+			body:   `{"name":"hedgehog","kind":"animal"}`,
+		},
+		"should fail once all responses fail the probe": {
+			bodies: []string{`<html>`, `<html>`},
+			err:    ErrResourceBodyProbe{},
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempt, _ := AttemptFromContext(req.Context())
+				if attempt > 0 {
+					time.Sleep(ms_20)
+				}
+				body := io.NopCloser(strings.NewReader(tcase.bodies[attempt]))
+				return &http.Response{StatusCode: http.StatusOK, Body: body, Request: req}, nil
+			})
+			rs := NewResourceWithOptions(
+				NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_5, http.StatusOK),
+				ResourceWithBodyProbe(8, verify),
+			)
+			rt := NewTransport(internal, WithCalls(1), WithResources(rs))
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			resp, err := rt.RoundTrip(req)
+			if tcase.err != nil {
+				var perr ErrResourceBodyProbe
+				if !errors.As(err, &perr) {
+					t.Fatalf("expected err %v but got %v", tcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("expected nil read err but got %v", err)
+			}
+			if string(b) != tcase.body {
+				t.Fatalf("expected body %q but got %q", tcase.body, string(b))
+			}
+		})
+	}
+}
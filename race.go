@@ -0,0 +1,371 @@
+package hedgehog
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// race defines matched request attempts state shared by original, hedged and replacement calls
+// from their launch until their results are collected and losing attempts are reaped.
+type race struct {
+	t           *transport
+	req, target *http.Request
+	rs          Resource
+	bo          *backoff
+	o           override
+	obs         observers
+	ctx         context.Context
+	dv          *divergence
+	quota       *Budget
+	calls       uint64
+	deadline    time.Duration
+	// results channel is never closed and fits all attempts, so attempts outliving the caller
+	// never block on sending their results nor send them on closed channel, reap consumes them instead.
+	res chan result
+	// each attempt has its own context, so losing attempts could be canceled without canceling the winner.
+	cancels  []context.CancelFunc
+	lock     sync.Mutex
+	launched int
+	pending  int
+	// hedged calls schedule state.
+	start   time.Time
+	base    time.Duration
+	next    uint64
+	hedge   <-chan time.Time
+	stop    <-chan struct{}
+	stopped bool
+	// collected outcome state.
+	resp            *http.Response
+	err             error
+	winner          uint64
+	replaced        uint64
+	strict          bool
+	primaryDone     bool
+	tie             bool
+	expired         bool
+	terminal        bool
+	grace           <-chan time.Time
+	prefer          <-chan time.Time
+	soft            <-chan time.Time
+	succeeded       []result
+	errs            []error
+	rejected        *http.Response
+	rejectedAttempt uint64
+	// losing responses that are already received are never discarded by the caller, they are left to the reaper.
+	losers []result
+}
+
+// cancel cancels all launched attempts.
+func (r *race) cancel() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, cancel := range r.cancels {
+		if cancel != nil {
+			cancel()
+		}
+	}
+}
+
+// launch returns provided attempt own context derived from the request context.
+func (r *race) launch(attempt uint64) context.Context {
+	actx, cancel := context.WithCancel(r.ctx)
+	r.lock.Lock()
+	r.cancels[attempt] = cancel
+	r.lock.Unlock()
+	r.launched++
+	return context.WithValue(actx, attemptKey{}, int(attempt))
+}
+
+// call makes provided attempt http call with provided attempt context and sends its result.
+func (r *race) call(attempt uint64, actx context.Context) {
+	t, rs, obs := r.t, r.rs, r.obs
+	req := r.req.Clone(actx)
+	h := rs.Hook(req)
+	start := time.Now()
+	var timedOut bool
+	send := func(res result, resp *http.Response) {
+		switch {
+		case actx.Err() == nil || timedOut:
+			r.bo.record(res.err != nil)
+		case res.err != nil && t.life.aborted():
+			res.err = ErrTransportClosed{}
+		case res.err != nil && r.ctx.Err() == nil:
+			// attempts canceled by the transport itself are neither successes nor failures.
+			res.err = errHedgeDone
+		}
+		elapsed := time.Since(start)
+		if obs.enabled() {
+			e := Event{Kind: EventAttemptEnd, Request: req, Resource: rs, Attempt: int(attempt), Elapsed: elapsed, Err: res.err}
+			if resp != nil {
+				e.StatusCode = resp.StatusCode
+			}
+			obs.emit(e)
+		}
+		if res.err != nil && res.err != errHedgeDone {
+			res.err = ErrAttemptFailed{Attempt: int(attempt), Elapsed: elapsed, Err: res.err}
+		}
+		r.res <- res
+	}
+	obs.emit(Event{Kind: EventAttemptStart, Request: req, Resource: rs, Attempt: int(attempt)})
+	if err := replay(req); err != nil {
+		send(result{attempt: attempt, err: err}, nil)
+		return
+	}
+	if err := t.authorize(req); err != nil {
+		send(result{attempt: attempt, err: err}, nil)
+		return
+	}
+	t.retarget(req, int(attempt))
+	t.modify(req, int(attempt))
+	var dump *dumper
+	if t.capture != nil {
+		dump = t.capture.start(req, attempt)
+	}
+	var timer *time.Timer
+	if t.timeout > 0 {
+		r.lock.Lock()
+		cancel := r.cancels[attempt]
+		r.lock.Unlock()
+		timer = time.AfterFunc(t.timeout, cancel)
+	}
+	internal := t.internal
+	if attempt > 0 && t.hedger != nil {
+		internal = t.hedger
+	}
+	resp, err := internal.RoundTrip(req)
+	// attempt timeout only covers the time until response headers are received.
+	if timer != nil && !timer.Stop() {
+		if err == nil {
+			t.discard(resp)
+			resp = nil
+		}
+		timedOut, err = true, ErrAttemptTimeout{Timeout: t.timeout}
+	}
+	if dump != nil {
+		dump.finish(resp, err)
+	}
+	if err != nil {
+		send(result{attempt: attempt, err: err}, nil)
+		return
+	}
+	err = t.check(rs, req, resp)
+	if err == nil {
+		err = probeOf(rs).check(resp)
+	}
+	if err != nil {
+		if !softFail(rs) {
+			if dump != nil {
+				dump.flush()
+			}
+			res := result{attempt: attempt, err: err}
+			if t.rejected {
+				res.rejected = resp
+			} else {
+				t.discard(resp)
+			}
+			send(res, resp)
+			return
+		}
+		obs.emit(Event{Kind: EventCheckViolation, Request: req, Resource: rs, Attempt: int(attempt), StatusCode: resp.StatusCode, Err: err})
+	}
+	h(resp)
+	// only responses that passed the resource check strictly are trusted, e.g. to be cached.
+	res := result{attempt: attempt, resp: resp, strict: err == nil}
+	if r.dv != nil {
+		res.prefix = r.dv.peek(resp)
+	}
+	send(res, resp)
+}
+
+// spawn launches provided hedged call attempt as long as hedged calls limits and budgets allow it.
+func (r *race) spawn(attempt uint64) {
+	var release func()
+	admitted := false
+	if !r.stopped {
+		release, admitted = r.t.admit(r.req, r.rs, r.quota)
+	}
+	if !admitted {
+		r.obs.emit(Event{Kind: EventHedgeSkipped, Request: r.req, Resource: r.rs, Attempt: int(attempt)})
+		return
+	}
+	r.pending++
+	go func(actx context.Context) {
+		defer release()
+		r.call(attempt, actx)
+	}(r.launch(attempt))
+}
+
+// hedges launches all due hedged calls as long as hedged calls limits and budgets allow it,
+// then it schedules the next hedged call if any, forced hedges launch the next hedged call right away.
+func (r *race) hedges(force bool) {
+	r.hedge = nil
+	if r.base == 0 {
+		r.base = time.Since(r.start)
+	}
+	for ; r.next <= r.calls; r.next++ {
+		if r.t.schedule != nil && !force {
+			if wait := r.t.schedule(int(r.next), r.base) - time.Since(r.start); wait > 0 {
+				r.hedge = time.After(wait)
+				return
+			}
+		}
+		force = r.o.force
+		r.spawn(r.next)
+	}
+}
+
+// collect collects attempts results until the winner is chosen or all attempts are done,
+// hedged calls are launched only if there is no winner yet once the delay elapses.
+func (r *race) collect() {
+	t := r.t
+	for r.pending > 0 || r.hedge != nil {
+		select {
+		case <-r.hedge:
+			r.hedges(false)
+		case res := <-r.res:
+			r.pending--
+			if res.attempt == 0 {
+				r.primaryDone = true
+			}
+			t.refund(res)
+			// oversized original call response is kept right away, so large bodies are never downloaded twice.
+			if res.attempt == 0 && res.resp != nil && t.oversized(res.resp.ContentLength) {
+				switch {
+				case r.dv != nil:
+					// the original call response becomes the verified winner, while the rest are closed by verification.
+					r.succeeded = append([]result{res}, r.succeeded...)
+				case r.resp != nil:
+					r.losers = append(r.losers, result{attempt: r.winner, resp: r.resp})
+				}
+				r.resp, r.err, r.winner, r.strict = res.resp, nil, res.attempt, res.strict
+				return
+			}
+			// failed attempt launches hedged calls right away instead of waiting for the delay.
+			if t.eager && r.hedge != nil && res.err != nil && res.err != errHedgeDone {
+				r.hedges(true)
+			}
+			// failed attempt is replaced right away independently from hedged calls launched after the delay.
+			if r.replaced < t.replacements && r.resp == nil && res.err != nil && res.err != errHedgeDone && !fatal(res.err) && r.ctx.Err() == nil {
+				r.spawn(r.calls + 1 + r.replaced)
+				r.replaced++
+			}
+			// keep only the best rejected response, by default the one with the lowest status code.
+			if res.rejected != nil {
+				if t.outranks(res.rejected, r.rejected) {
+					if r.rejected != nil {
+						r.losers = append(r.losers, result{attempt: r.rejectedAttempt, rejected: r.rejected})
+					}
+					r.rejected, r.rejectedAttempt = res.rejected, res.attempt
+				} else {
+					r.losers = append(r.losers, result{attempt: res.attempt, rejected: res.rejected})
+				}
+			}
+			switch {
+			case res.resp != nil && r.dv != nil:
+				r.succeeded = append(r.succeeded, res)
+				// keep collecting results within grace window to verify them.
+				if r.resp == nil {
+					r.resp, r.err, r.winner, r.strict = res.resp, nil, res.attempt, res.strict
+					r.grace, r.hedge = time.After(r.dv.grace), nil
+				}
+			case res.resp != nil && r.resp != nil:
+				// the hedged call response is held while waiting for the original call.
+				if res.attempt != 0 {
+					r.losers = append(r.losers, result{attempt: res.attempt, resp: res.resp})
+					continue
+				}
+				r.losers = append(r.losers, result{attempt: r.winner, resp: r.resp})
+				r.resp, r.winner, r.strict, r.tie = res.resp, res.attempt, res.strict, true
+				return
+			case res.resp != nil && res.attempt != 0 && t.preference > 0 && !r.primaryDone:
+				r.resp, r.err, r.winner, r.strict = res.resp, nil, res.attempt, res.strict
+				r.prefer, r.hedge = time.After(t.preference), nil
+			case res.resp != nil:
+				r.resp, r.err, r.winner, r.strict = res.resp, nil, res.attempt, res.strict
+				return
+			case res.attempt == 0 && r.resp != nil:
+				// the original call failed while the hedged call response is held.
+				return
+			case res.err != nil && r.resp == nil && fatal(res.err):
+				// terminal check failure is returned right away as other attempts would fail the same way.
+				r.err, r.terminal = res.err, true
+				return
+			case res.err == errHedgeDone:
+				// internally canceled attempts errors are never returned to the caller.
+			case res.err != nil:
+				// accumulate all occurred errors in case no attempt succeeds.
+				r.errs = append(r.errs, res.err)
+			}
+		case <-r.stop:
+			// stopped hedged calls are canceled right away except the held hedged call response, the original call keeps going.
+			r.stop, r.stopped, r.hedge = nil, true, nil
+			r.lock.Lock()
+			for attempt, cancel := range r.cancels {
+				if attempt != 0 && cancel != nil && (r.resp == nil || uint64(attempt) != r.winner) {
+					cancel()
+				}
+			}
+			r.lock.Unlock()
+		case <-r.grace:
+			return
+		case <-r.prefer:
+			return
+		case <-r.soft:
+			if r.resp == nil {
+				r.expired, r.err = true, ErrSoftDeadline{Deadline: r.deadline, Err: attemptsFailed(r.launched, r.errs)}
+			}
+			return
+		case <-r.ctx.Done():
+			if r.resp == nil {
+				r.err = r.ctx.Err()
+			}
+			return
+		}
+	}
+}
+
+// merge merges set cookies of all succeeded and already received losing responses into provided winner response,
+// losing responses bodies are left to the reaper.
+func (r *race) merge(resp *http.Response) {
+	for _, res := range r.succeeded {
+		if res.attempt != r.winner {
+			mergeSetCookies(resp, res.resp)
+		}
+	}
+	for ready := true; ready && r.pending > 0; {
+		select {
+		case res := <-r.res:
+			r.pending--
+			if res.resp != nil {
+				mergeSetCookies(resp, res.resp)
+			}
+			r.losers = append(r.losers, res)
+		default:
+			ready = false
+		}
+	}
+}
+
+// reap cancels and reaps all losing attempts in background once the outcome is reported and then calls provided release,
+// while provided kept attempt is canceled only once provided response body is closed.
+func (r *race) reap(resp *http.Response, keep int, release func()) {
+	if keep >= 0 {
+		resp.Body = cancelBody{ReadCloser: resp.Body, cancel: r.cancels[keep]}
+		// streaming winner may never end, so losing attempts are canceled right away before they are reaped.
+		if r.t.streaming(resp) {
+			r.lock.Lock()
+			for attempt, cancel := range r.cancels {
+				if cancel != nil && attempt != keep {
+					cancel()
+				}
+			}
+			r.lock.Unlock()
+		}
+	}
+	go func() {
+		r.t.reap(r.res, r.pending, r.cancels, keep, r.losers)
+		release()
+	}()
+}
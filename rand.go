@@ -0,0 +1,64 @@
+package hedgehog
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Rand defines random numbers source that drives hedged transport probabilistic decisions.
+// Rand might be called concurrently from multiple goroutines.
+type Rand interface {
+	Float64() float64
+}
+
+type lockedRand struct {
+	lock sync.Mutex
+	rnd  *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.rnd.Float64()
+}
+
+// WithRandSource sets hedged transport random numbers source that is used by all its probabilistic decisions,
+// including experiment cohorts assignment (overriding experiment seed), hedged requests sampling and resources slow start sampling.
+// By default each hedged transport uses its own time seeded random numbers source.
+func WithRandSource(rnd Rand) TransportOption {
+	return func(t *transport) {
+		t.rand = rnd
+	}
+}
+
+// ResourceWithRandSource sets the resource random numbers source that is used by the resource probabilistic decisions,
+// by default the resource uses hedged transport random numbers source.
+func ResourceWithRandSource(rnd Rand) ResourceOption {
+	return func(r *decorated) {
+		r.rand = rnd
+	}
+}
+
+// WithHedgeSampling sets hedged transport fraction of matched requests that are hedged, so hedging could be ramped up gradually.
+// Each matched request is sampled independently, unsampled requests make no hedged calls yet their responses are still checked
+// and still feed the resource hooks, so the resource latency learning isn't starved. By default all matched requests are hedged.
+func WithHedgeSampling(fraction float64) TransportOption {
+	return func(t *transport) {
+		t.unsampled = 1.0 - math.Max(math.Min(fraction, 1.0), 0.0)
+	}
+}
+
+// sample decides whether matched request should be hedged according to transport hedged requests sampling.
+func (t *transport) sample() bool {
+	return t.unsampled <= 0 || t.unsampled < 1 && t.rand.Float64() >= t.unsampled
+}
+
+func defaultRand() Rand {
+	return newLockedRand(time.Now().UnixNano())
+}
@@ -0,0 +1,62 @@
+package hedgehog
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestWithHedgeSampling(t *testing.T) {
+	const requests = 2000
+	ttable := map[string]struct {
+		fraction float64
+		min, max int
+	}{
+		"should hedge no requests with zero fraction": {
+			fraction: 0,
+			min:      0,
+			max:      0,
+		},
+		"should hedge roughly quarter of requests with quarter fraction": {
+			fraction: 0.25,
+			min:      requests/4 - 100,
+			max:      requests/4 + 100,
+		},
+		"should hedge all requests with full fraction": {
+			fraction: 1,
+			min:      requests,
+			max:      requests,
+		},
+	}
+	for tname, tcase := range ttable {
+		fraction, min, max := tcase.fraction, tcase.min, tcase.max
+		t.Run(tname, func(t *testing.T) {
+			// original calls always fail, so only hedged requests succeed.
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if attempt, _ := AttemptFromContext(req.Context()); attempt == 0 {
+					return nil, errors.New("upstream failure")
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+			})
+			rt := NewTransport(
+				internal,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_0, http.StatusOK)),
+				WithHedgeSampling(fraction),
+				WithRandSource(newLockedRand(42)),
+			)
+			var hedged int
+			for i := 0; i < requests; i++ {
+				req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+				if resp, err := rt.RoundTrip(req); err == nil {
+					_ = resp.Body.Close()
+					hedged++
+				}
+			}
+			if hedged < min || hedged > max {
+				t.Fatalf("expected hedged requests be within [%d, %d] but got %d", min, max, hedged)
+			}
+		})
+	}
+}
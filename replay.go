@@ -0,0 +1,223 @@
+package hedgehog
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayConfig defines candidate hedging configuration replayed against recorded latencies trace.
+// Delayer is called once per replay to create fresh delayer, if nil delayer is provided hedged calls start right away.
+type ReplayConfig struct {
+	Name    string
+	Calls   uint64
+	Delayer func() Delayer
+}
+
+// ReplayQuantiles defines replayed latency quantiles.
+type ReplayQuantiles struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+}
+
+// ReplayReport defines simulated hedging report of candidate hedging configuration.
+// HedgeRate is the fraction of requests that made hedged calls,
+// WastedWork is the fraction of additional upstream work spent by hedged calls compared to no hedging.
+type ReplayReport struct {
+	Name       string
+	Requests   int
+	HedgeRate  float64
+	WastedWork float64
+	Baseline   ReplayQuantiles
+	Hedged     ReplayQuantiles
+}
+
+// Replay feeds recorded latencies trace through provided candidate hedging configuration and simulates hedging decisions.
+// Each trace sample is treated as the original call latency, request makes hedged calls if its latency exceeds
+// the delay returned by the delayer at that point, then the sample is recorded by the delayer.
+// Hedged calls latencies are assumed to be independent draws from the trace, so estimated quantiles
+// are calculated over all possible hedged calls outcomes weighted by their probability.
+// Hedged outcomes distribution is never materialized, so the replay takes O(n log n) memory and time in the trace size.
+func Replay(samples []time.Duration, cfg ReplayConfig) ReplayReport {
+	report := ReplayReport{Name: cfg.Name, Requests: len(samples)}
+	if len(samples) == 0 {
+		return report
+	}
+	delayer := NewDelayerStatic(0)
+	if cfg.Delayer != nil {
+		delayer = cfg.Delayer()
+	}
+	draws := make([]time.Duration, len(samples))
+	copy(draws, samples)
+	sort.Slice(draws, func(i, j int) bool {
+		return draws[i] < draws[j]
+	})
+	// cumulative weights define probability of the fastest of all hedged calls being one of the first sorted draws,
+	// while cumulative latencies define the same weighted sum of the first sorted draws latencies.
+	n, k := float64(len(draws)), float64(cfg.Calls)
+	weights, latencies := make([]float64, len(draws)+1), make([]float64, len(draws)+1)
+	for i, d := range draws {
+		w := math.Pow((n-float64(i))/n, k) - math.Pow((n-float64(i)-1)/n, k)
+		weights[i+1], latencies[i+1] = weights[i]+w, latencies[i]+w*float64(d)
+	}
+	baseline := make([]weighted, 0, len(samples))
+	var plain []time.Duration
+	var hedged []hedgedSample
+	var work, extra float64
+	for _, s := range samples {
+		baseline = append(baseline, weighted{latency: s, weight: 1})
+		work += float64(s)
+		delay := delayer.Delay()
+		delayer.Record(s)
+		if cfg.Calls == 0 || s <= delay {
+			plain = append(plain, s)
+			continue
+		}
+		hedged = append(hedged, hedgedSample{latency: s, delay: delay})
+		// request lasts until the fastest call returns and all other calls are canceled,
+		// so draws faster than the original call remainder finish the request, while the rest never do.
+		m := sort.Search(len(draws), func(i int) bool {
+			return delay+draws[i] >= s
+		})
+		total := latencies[m] + weights[m]*float64(delay) + (weights[len(draws)]-weights[m])*float64(s)
+		extra += total + k*(total-float64(delay)) - float64(s)
+	}
+	report.HedgeRate = float64(len(hedged)) / n
+	if work > 0 {
+		report.WastedWork = extra / work
+	}
+	report.Baseline = replayQuantiles(baseline)
+	report.Hedged = hedgedQuantiles(plain, hedged, draws, weights)
+	return report
+}
+
+// ReplaySweep replays recorded latencies trace against each provided candidate hedging configuration
+// and returns reports table in the same order.
+func ReplaySweep(samples []time.Duration, cfgs ...ReplayConfig) []ReplayReport {
+	reports := make([]ReplayReport, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		reports = append(reports, Replay(samples, cfg))
+	}
+	return reports
+}
+
+// ReadReplaySamples reads recorded latencies trace from provided csv reader.
+// Latency is read from the first column of each record either as duration string or as number of milliseconds,
+// the first record is skipped if it can't be parsed to allow csv header.
+func ReadReplaySamples(r io.Reader) ([]time.Duration, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var samples []time.Duration
+	for line := 0; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s, err := parseReplaySample(record[0])
+		if err != nil {
+			if line == 0 {
+				continue
+			}
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+}
+
+func parseReplaySample(field string) (time.Duration, error) {
+	field = strings.TrimSpace(field)
+	if ms, err := strconv.ParseFloat(field, 64); err == nil {
+		return time.Duration(ms * float64(time.Millisecond)), nil
+	}
+	return time.ParseDuration(field)
+}
+
+type weighted struct {
+	latency time.Duration
+	weight  float64
+}
+
+func replayQuantiles(samples []weighted) ReplayQuantiles {
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].latency < samples[j].latency
+	})
+	var total float64
+	for _, s := range samples {
+		total += s.weight
+	}
+	quantile := func(q float64) time.Duration {
+		var cum float64
+		for _, s := range samples {
+			cum += s.weight
+			// tolerate floating point weights rounding errors.
+			if cum >= total*q-1e-9 {
+				return s.latency
+			}
+		}
+		return samples[len(samples)-1].latency
+	}
+	return ReplayQuantiles{P50: quantile(0.5), P95: quantile(0.95), P99: quantile(0.99)}
+}
+
+// hedgedSample defines replayed request that made hedged calls after the delay.
+type hedgedSample struct {
+	latency time.Duration
+	delay   time.Duration
+}
+
+// hedgedQuantiles returns replayed latency quantiles of provided requests without and with hedged calls,
+// hedged requests latency distribution is derived from provided sorted draws and their cumulative weights,
+// so quantiles are found by binary search over latency distribution function instead of materialized outcomes.
+func hedgedQuantiles(plain []time.Duration, hedged []hedgedSample, draws []time.Duration, weights []float64) ReplayQuantiles {
+	sort.Slice(plain, func(i, j int) bool {
+		return plain[i] < plain[j]
+	})
+	var max time.Duration
+	for _, d := range draws {
+		if d > max {
+			max = d
+		}
+	}
+	// cdf returns expected number of requests that finished within provided latency.
+	cdf := func(x time.Duration) float64 {
+		c := float64(sort.Search(len(plain), func(i int) bool {
+			return plain[i] > x
+		}))
+		for _, h := range hedged {
+			switch {
+			case x >= h.latency:
+				c++
+			case x >= h.delay:
+				m := sort.Search(len(draws), func(i int) bool {
+					return h.delay+draws[i] > x
+				})
+				c += weights[m]
+			}
+		}
+		return c
+	}
+	total := float64(len(plain) + len(hedged))
+	quantile := func(q float64) time.Duration {
+		lo, hi := time.Duration(0), max
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			// tolerate floating point weights rounding errors.
+			if cdf(mid) >= total*q-1e-9 {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		return lo
+	}
+	return ReplayQuantiles{P50: quantile(0.5), P95: quantile(0.95), P99: quantile(0.99)}
+}
@@ -0,0 +1,153 @@
+package hedgehog
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	ms := func(vals ...int) []time.Duration {
+		samples := make([]time.Duration, 0, len(vals))
+		for _, v := range vals {
+			samples = append(samples, time.Duration(v)*time.Millisecond)
+		}
+		return samples
+	}
+	table := map[string]struct {
+		samples []time.Duration
+		cfg     ReplayConfig
+		report  ReplayReport
+	}{
+		"replay should produce empty report for empty trace": {
+			cfg:    ReplayConfig{Name: "empty", Calls: 1},
+			report: ReplayReport{Name: "empty"},
+		},
+		"replay should not hedge without hedged calls": {
+			samples: ms(10, 40),
+			cfg:     ReplayConfig{Delayer: func() Delayer { return NewDelayerStatic(ms_1) }},
+			report: ReplayReport{
+				Requests: 2,
+				Baseline: ReplayQuantiles{P50: ms_10, P95: 40 * time.Millisecond, P99: 40 * time.Millisecond},
+				Hedged:   ReplayQuantiles{P50: ms_10, P95: 40 * time.Millisecond, P99: 40 * time.Millisecond},
+			},
+		},
+		"replay should hedge only slow requests with static delayer": {
+			samples: ms(10, 10, 10, 40),
+			cfg:     ReplayConfig{Name: "static", Calls: 1, Delayer: func() Delayer { return NewDelayerStatic(20 * time.Millisecond) }},
+			report: ReplayReport{
+				Name:       "static",
+				Requests:   4,
+				HedgeRate:  0.25,
+				WastedWork: 5.0 / 70.0,
+				Baseline:   ReplayQuantiles{P50: ms_10, P95: 40 * time.Millisecond, P99: 40 * time.Millisecond},
+				// 40ms request becomes 30ms with 3/4 probability.
+				Hedged: ReplayQuantiles{P50: ms_10, P95: 40 * time.Millisecond, P99: 40 * time.Millisecond},
+			},
+		},
+		"replay should improve tail latency with multiple hedged calls": {
+			samples: ms(10, 10, 10, 40),
+			cfg:     ReplayConfig{Calls: 3, Delayer: func() Delayer { return NewDelayerStatic(20 * time.Millisecond) }},
+			report: ReplayReport{
+				Requests:  4,
+				HedgeRate: 0.25,
+				// 40ms request becomes 30ms with 63/64 probability, so it lasts 30.15625ms on average.
+				WastedWork: (4*30.15625 - 100) / 70.0,
+				Baseline:   ReplayQuantiles{P50: ms_10, P95: 40 * time.Millisecond, P99: 40 * time.Millisecond},
+				Hedged:     ReplayQuantiles{P50: ms_10, P95: 30 * time.Millisecond, P99: 30 * time.Millisecond},
+			},
+		},
+		"replay should follow dynamic delayer adjustments": {
+			samples: ms(10, 30, 30),
+			cfg:     ReplayConfig{Calls: 1, Delayer: func() Delayer { return NewDelayerAverage(ms_5, 2) }},
+			report: ReplayReport{
+				Requests:   3,
+				HedgeRate:  1.0,
+				WastedWork: 30.0 / 70.0,
+				Baseline:   ReplayQuantiles{P50: 30 * time.Millisecond, P95: 30 * time.Millisecond, P99: 30 * time.Millisecond},
+				Hedged:     ReplayQuantiles{P50: 30 * time.Millisecond, P95: 30 * time.Millisecond, P99: 30 * time.Millisecond},
+			},
+		},
+		"replay should account fastest of multiple hedged calls": {
+			samples: ms(10, 10, 100, 100),
+			cfg:     ReplayConfig{Calls: 2, Delayer: func() Delayer { return NewDelayerStatic(ms_10) }},
+			report: ReplayReport{
+				Requests:  4,
+				HedgeRate: 0.5,
+				// each slow request becomes 20ms with 3/4 probability and otherwise stays 100ms,
+				// so it lasts 40ms on average and spends 2*30ms on hedged calls.
+				WastedWork: 2 * (40.0 + 60.0 - 100.0) / 220.0,
+				Baseline:   ReplayQuantiles{P50: ms_10, P95: 100 * time.Millisecond, P99: 100 * time.Millisecond},
+				Hedged:     ReplayQuantiles{P50: ms_10, P95: 100 * time.Millisecond, P99: 100 * time.Millisecond},
+			},
+		},
+	}
+	for tname, tcase := range table {
+		t.Run(tname, func(t *testing.T) {
+			report := Replay(tcase.samples, tcase.cfg)
+			if math.Abs(report.WastedWork-tcase.report.WastedWork) > 1e-9 {
+				t.Fatalf("expected wasted work %v but got %v", tcase.report.WastedWork, report.WastedWork)
+			}
+			report.WastedWork = tcase.report.WastedWork
+			if !reflect.DeepEqual(report, tcase.report) {
+				t.Fatalf("expected report %+v but got %+v", tcase.report, report)
+			}
+		})
+	}
+}
+
+func TestReplayLargeTrace(t *testing.T) {
+	// slow tail of every 25th sample is hedged, so hedged outcomes of large trace would never fit in memory.
+	samples := make([]time.Duration, 100000)
+	for i := range samples {
+		samples[i] = ms_10
+		if i%25 == 0 {
+			samples[i] = ms_100
+		}
+	}
+	report := Replay(samples, ReplayConfig{Calls: 1, Delayer: func() Delayer { return NewDelayerStatic(ms_50) }})
+	if report.HedgeRate != 0.04 {
+		t.Fatalf("expected hedge rate %v but got %v", 0.04, report.HedgeRate)
+	}
+	// slow request becomes 60ms with 24/25 probability.
+	expected := ReplayQuantiles{P50: ms_10, P95: ms_10, P99: ms_50 + ms_10}
+	if report.Hedged != expected {
+		t.Fatalf("expected hedged quantiles %+v but got %+v", expected, report.Hedged)
+	}
+}
+
+func TestReplaySweep(t *testing.T) {
+	samples := []time.Duration{ms_10, ms_10, ms_10, 40 * time.Millisecond}
+	var cfgs []ReplayConfig
+	for _, delay := range []time.Duration{ms_5, 20 * time.Millisecond, 50 * time.Millisecond} {
+		delay := delay
+		cfgs = append(cfgs, ReplayConfig{Name: delay.String(), Calls: 1, Delayer: func() Delayer { return NewDelayerStatic(delay) }})
+	}
+	reports := ReplaySweep(samples, cfgs...)
+	rates := make([]float64, 0, len(reports))
+	for i, r := range reports {
+		if r.Name != cfgs[i].Name {
+			t.Fatalf("expected report %q but got %q", cfgs[i].Name, r.Name)
+		}
+		rates = append(rates, r.HedgeRate)
+	}
+	if expected := []float64{1.0, 0.25, 0}; !reflect.DeepEqual(rates, expected) {
+		t.Fatalf("expected hedge rates %v but got %v", expected, rates)
+	}
+}
+
+func TestReadReplaySamples(t *testing.T) {
+	samples, err := ReadReplaySamples(strings.NewReader("latency,path\n10,/a\n1.5,/b\n2s,/c\n"))
+	if err != nil {
+		t.Fatalf("expected nil err but got %v", err)
+	}
+	expected := []time.Duration{ms_10, 1500 * time.Microsecond, 2 * time.Second}
+	if !reflect.DeepEqual(samples, expected) {
+		t.Fatalf("expected samples %v but got %v", expected, samples)
+	}
+	if _, err := ReadReplaySamples(strings.NewReader("10\nfast\n")); err == nil {
+		t.Fatal("expected non nil err but got nil")
+	}
+}
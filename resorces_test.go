@@ -80,3 +80,68 @@ func TestResorces(t *testing.T) {
 		})
 	}
 }
+
+func TestResourceSchedule(t *testing.T) {
+	kyiv := time.FixedZone("EET", 2*60*60)
+	entries := []ScheduleEntry{
+		{From: 2 * time.Hour, To: 4 * time.Hour, Delay: ms_20},
+		{From: 23 * time.Hour, To: 1 * time.Hour, Delay: ms_50},
+		{From: 12 * time.Hour, To: 13 * time.Hour, Location: kyiv, Delay: ms_100},
+		{Delay: ms_5},
+		{Delay: ms_8},
+	}
+	ttable := map[string]struct {
+		now   time.Time
+		after time.Duration
+	}{
+		"schedule resource should use default entry outside of windows": {
+			now:   time.Date(2021, 1, 1, 1, 59, 59, 0, time.UTC),
+			after: ms_5,
+		},
+		"schedule resource should use window entry at window start": {
+			now:   time.Date(2021, 1, 1, 2, 0, 0, 0, time.UTC),
+			after: ms_20,
+		},
+		"schedule resource should use window entry before window end": {
+			now:   time.Date(2021, 1, 1, 3, 59, 59, 0, time.UTC),
+			after: ms_20,
+		},
+		"schedule resource should use default entry at window end": {
+			now:   time.Date(2021, 1, 1, 4, 0, 0, 0, time.UTC),
+			after: ms_5,
+		},
+		"schedule resource should use window entry crossing midnight before midnight": {
+			now:   time.Date(2021, 1, 1, 23, 30, 0, 0, time.UTC),
+			after: ms_50,
+		},
+		"schedule resource should use window entry crossing midnight after midnight": {
+			now:   time.Date(2021, 1, 2, 0, 30, 0, 0, time.UTC),
+			after: ms_50,
+		},
+		"schedule resource should use window entry in its location": {
+			now:   time.Date(2021, 1, 1, 10, 30, 0, 0, time.UTC),
+			after: ms_100,
+		},
+		"schedule resource should use window entry in provided time location": {
+			now:   time.Date(2021, 1, 1, 12, 30, 0, 0, kyiv),
+			after: ms_100,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			t.Parallel()
+			res := NewResourceScheduleWithClock("", regexp.MustCompile(``), entries, func() time.Time { return tcase.now }, 0)
+			if d := res.(delayer).duration(); d != tcase.after {
+				t.Fatalf("expected resource delay %s but got %s", tcase.after, d)
+			}
+		})
+	}
+}
+
+func TestResourceScheduleNilClock(t *testing.T) {
+	res := NewResourceScheduleWithClock("", regexp.MustCompile(``), []ScheduleEntry{{Delay: ms_5}}, nil, 0)
+	if d := res.(delayer).duration(); d != ms_5 {
+		t.Fatalf("expected resource delay %s but got %s", ms_5, d)
+	}
+}
@@ -20,6 +20,21 @@ func (err ErrResourceUnexpectedResponseCode) Error() string {
 	return fmt.Sprintf("resource check failed: received unexpected response status code %d", err.StatusCode)
 }
 
+// ErrResourceCheckFatal defines resource response check error wrapper that marks the check failure as terminal,
+// as it's expected to be identical for any other http call. Once any call check fails with it, hedged transport
+// doesn't wait for other calls, instead it cancels them and returns the error right away.
+type ErrResourceCheckFatal struct {
+	Err error
+}
+
+func (err ErrResourceCheckFatal) Error() string {
+	return fmt.Sprintf("fatal %v", err.Err)
+}
+
+func (err ErrResourceCheckFatal) Unwrap() error {
+	return err.Err
+}
+
 // Resource defines abstract http resource that is capable of:
 // - matching http request applicability
 // - checking http request validity
@@ -31,6 +46,16 @@ type Resource interface {
 	Hook(*http.Request) func(*http.Response)
 }
 
+// delayer defines resource that is capable of returning its current delay.
+type delayer interface {
+	duration() time.Duration
+}
+
+// learner defines resource that dynamically adjusts its delay once it learned enough latencies.
+type learner interface {
+	learned() bool
+}
+
 type static struct {
 	method string
 	url    *regexp.Regexp
@@ -55,8 +80,16 @@ func NewResourceStatic(method string, url *regexp.Regexp, delay time.Duration, a
 	return rs
 }
 
+func (r static) base() static {
+	return r
+}
+
 func (r static) After() <-chan time.Time {
-	return time.After(r.delay)
+	return time.After(r.duration())
+}
+
+func (r static) duration() time.Duration {
+	return r.delay
 }
 
 func (r static) Match(req *http.Request) bool {
@@ -80,6 +113,8 @@ func (r static) Hook(*http.Request) func(*http.Response) {
 	return func(*http.Response) {}
 }
 
+func (r static) record(time.Duration) {}
+
 type average struct {
 	static
 	sum      int64
@@ -104,29 +139,40 @@ func NewResourceAverage(method string, url *regexp.Regexp, delay time.Duration,
 }
 
 func (r *average) After() <-chan time.Time {
+	return time.After(r.duration())
+}
+
+func (r *average) duration() time.Duration {
 	delay := r.delay
 	count := atomic.LoadInt64(&r.count)
 	if count >= r.capacity {
 		delay = time.Duration(atomic.LoadInt64(&r.sum) / count)
 	}
-	return time.After(delay)
+	return delay
+}
+
+func (r *average) learned() bool {
+	return atomic.LoadInt64(&r.count) >= r.capacity
 }
 
 func (r *average) Hook(*http.Request) func(*http.Response) {
 	t := time.Now()
 	return func(*http.Response) {
-		d := time.Since(t)
-		oldval := atomic.LoadInt64(&r.sum)
-		newval := atomic.AddInt64(&r.sum, int64(d))
-		count := atomic.AddInt64(&r.count, 1)
-		// in case of overflow:
-		// - calculate average value on capacity+1
-		// - replace current sum and count with it
-		if newval < 0 || count > r.capacity*2 {
-			val := oldval / count * (r.capacity + 1)
-			atomic.StoreInt64(&r.sum, val)
-			atomic.StoreInt64(&r.count, r.capacity+1)
-		}
+		r.record(time.Since(t))
+	}
+}
+
+func (r *average) record(d time.Duration) {
+	oldval := atomic.LoadInt64(&r.sum)
+	newval := atomic.AddInt64(&r.sum, int64(d))
+	count := atomic.AddInt64(&r.count, 1)
+	// in case of overflow:
+	// - calculate average value on capacity+1
+	// - replace current sum and count with it
+	if newval < 0 || count > r.capacity*2 {
+		val := oldval / count * (r.capacity + 1)
+		atomic.StoreInt64(&r.sum, val)
+		atomic.StoreInt64(&r.count, r.capacity+1)
 	}
 }
 
@@ -162,6 +208,10 @@ func NewResourcePercentiles(method string, url *regexp.Regexp, delay time.Durati
 }
 
 func (r *percentiles) After() <-chan time.Time {
+	return time.After(r.duration())
+}
+
+func (r *percentiles) duration() time.Duration {
 	delay := r.delay
 	r.lock.RLock()
 	if l := int64(len(r.latencies)); l >= r.capacity/2 {
@@ -173,19 +223,106 @@ func (r *percentiles) After() <-chan time.Time {
 		delay = lat[int(math.Round(float64(l)*r.percentile))-1]
 	}
 	r.lock.RUnlock()
-	return time.After(delay)
+	return delay
+}
+
+func (r *percentiles) learned() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return int64(len(r.latencies)) >= r.capacity/2
 }
 
 func (r *percentiles) Hook(*http.Request) func(*http.Response) {
 	t := time.Now()
 	return func(*http.Response) {
-		d := time.Since(t)
-		r.lock.Lock()
-		r.latencies = append(r.latencies, d)
-		// in case of overflow: just drop half of the buffer
-		if int64(len(r.latencies)) >= r.capacity {
-			r.latencies = r.latencies[r.capacity/2:]
+		r.record(time.Since(t))
+	}
+}
+
+func (r *percentiles) record(d time.Duration) {
+	r.lock.Lock()
+	r.latencies = append(r.latencies, d)
+	// in case of overflow: just drop half of the buffer
+	if int64(len(r.latencies)) >= r.capacity {
+		r.latencies = r.latencies[r.capacity/2:]
+	}
+	r.lock.Unlock()
+}
+
+// ScheduleEntry defines resource schedule daily time window with its delay.
+// Window starts at From and ends at To offsets since midnight in provided location (UTC if nil),
+// windows with From greater than To are crossing midnight.
+// Entry with empty window (From equal to To) defines default delay used outside of all other windows.
+type ScheduleEntry struct {
+	From     time.Duration
+	To       time.Duration
+	Location *time.Location
+	Delay    time.Duration
+}
+
+func (e ScheduleEntry) contains(t time.Time) bool {
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	offset := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+	if e.From <= e.To {
+		return e.From <= offset && offset < e.To
+	}
+	return offset >= e.From || offset < e.To
+}
+
+type schedule struct {
+	static
+	entries []ScheduleEntry
+	now     func() time.Time
+}
+
+// NewResourceSchedule returns new resource instance that waits for delay picked by current time of day.
+// Returned resource picks delay of the first provided schedule entry which window contains current time,
+// if no such entry found it uses delay of default entry (entry with empty window) or no delay.
+// Returned resource matches each request against both provided http method and full url regexp.
+// Returned resource checks if response result http code is included in provided allowed codes,
+// if it is not it returnes `ErrResourceUnexpectedResponseCode`.
+func NewResourceSchedule(method string, url *regexp.Regexp, entries []ScheduleEntry, allowedCodes ...int) Resource {
+	return NewResourceScheduleWithClock(method, url, entries, time.Now, allowedCodes...)
+}
+
+// NewResourceScheduleWithClock returns new resource instance that behaves exactly as resource returned by `NewResourceSchedule`,
+// but picks delay by current time of day provided by specified clock, e.g. fake clock to step through schedule windows in tests.
+// Nil clock means `time.Now` is used.
+func NewResourceScheduleWithClock(method string, url *regexp.Regexp, entries []ScheduleEntry, now func() time.Time, allowedCodes ...int) Resource {
+	if now == nil {
+		now = time.Now
+	}
+	return schedule{
+		static:  NewResourceStatic(method, url, 0, allowedCodes...).(static),
+		entries: entries,
+		now:     now,
+	}
+}
+
+func (r schedule) After() <-chan time.Time {
+	return time.After(r.duration())
+}
+
+func (r schedule) duration() time.Duration {
+	now := r.now()
+	delay := r.delay
+	def := false
+	for _, e := range r.entries {
+		switch {
+		case e.From == e.To:
+			if !def {
+				delay, def = e.Delay, true
+			}
+		case e.contains(now):
+			return e.Delay
 		}
-		r.lock.Unlock()
 	}
+	return delay
 }
@@ -0,0 +1,78 @@
+package hedgehog
+
+import "time"
+
+// HedgeSchedule defines hedged calls launch schedule, it returns provided hedged call attempt (1..N)
+// launch offset since original call start for provided base delay, that is the resource delay
+// or the effective delay that already includes server policy, error backoff and per call overrides.
+type HedgeSchedule func(attempt int, delay time.Duration) time.Duration
+
+// ScheduleSimultaneous launches all hedged calls together once the delay elapses, it's the default schedule.
+func ScheduleSimultaneous(_ int, delay time.Duration) time.Duration {
+	return delay
+}
+
+// ScheduleStagger launches each subsequent hedged call after another delay: delay, 2*delay, 3*delay, etc.
+func ScheduleStagger(attempt int, delay time.Duration) time.Duration {
+	return time.Duration(attempt) * delay
+}
+
+// ScheduleExponential launches each subsequent hedged call after doubled offset: delay, 2*delay, 4*delay, etc.
+func ScheduleExponential(attempt int, delay time.Duration) time.Duration {
+	return delay << uint(attempt-1)
+}
+
+// WithHedgeSchedule sets hedged transport hedged calls launch schedule, by default all hedged calls
+// are launched together once the delay elapses. Once any call wins no more hedged calls are launched.
+// For resources that couldn't report their delay, the base delay is measured once the resource delay elapses.
+func WithHedgeSchedule(schedule HedgeSchedule) TransportOption {
+	return func(t *transport) {
+		t.schedule = schedule
+	}
+}
+
+type window struct {
+	allowed func(time.Time) bool
+	now     func() time.Time
+}
+
+// WithHedgeWindow sets hedged transport hedging time window that is evaluated for each matched request,
+// outside of the window matched requests make no hedged calls yet their responses are still checked
+// and still feed the resource hooks, so the resource latency learning keeps going, e.g. to spare upstream capacity
+// during nightly batch jobs. See `DailyWindow` for simple daily windows. By default hedging is always allowed.
+func WithHedgeWindow(allowed func(time.Time) bool) TransportOption {
+	return withHedgeWindow(allowed, time.Now)
+}
+
+func withHedgeWindow(allowed func(time.Time) bool, now func() time.Time) TransportOption {
+	return func(t *transport) {
+		if allowed == nil {
+			t.window = nil
+			return
+		}
+		t.window = &window{allowed: allowed, now: now}
+	}
+}
+
+// allow returns whether hedging is allowed right now, nil window always allows hedging.
+func (w *window) allow() bool {
+	return w == nil || w.allowed(w.now())
+}
+
+// DailyWindow returns daily time window that contains times of day within [from, to) in provided location,
+// windows with from after to wrap around midnight, e.g. `DailyWindow(4*time.Hour, 2*time.Hour, time.UTC)`
+// allows hedging all day except between 02:00 and 04:00 UTC. Nil location means UTC.
+func DailyWindow(from, to time.Duration, loc *time.Location) func(time.Time) bool {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return func(now time.Time) bool {
+		now = now.In(loc)
+		h, m, s := now.Clock()
+		day := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second + time.Duration(now.Nanosecond())
+		if from <= to {
+			return day >= from && day < to
+		}
+		return day >= from || day < to
+	}
+}
@@ -0,0 +1,208 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithHedgeSchedule(t *testing.T) {
+	ttable := map[string]struct {
+		schedule HedgeSchedule
+		win      time.Duration
+		offsets  []time.Duration
+	}{
+		"should launch all hedged calls together by default": {
+			win:     ms_50,
+			offsets: []time.Duration{ms_0, ms_10, ms_10, ms_10},
+		},
+		"should launch hedged calls together with simultaneous schedule": {
+			schedule: ScheduleSimultaneous,
+			win:      ms_50,
+			offsets:  []time.Duration{ms_0, ms_10, ms_10, ms_10},
+		},
+		"should launch staggered hedged calls": {
+			schedule: ScheduleStagger,
+			win:      ms_50,
+			offsets:  []time.Duration{ms_0, ms_10, ms_20, 3 * ms_10},
+		},
+		"should launch exponential hedged calls": {
+			schedule: ScheduleExponential,
+			win:      ms_50,
+			offsets:  []time.Duration{ms_0, ms_10, ms_20, 4 * ms_10},
+		},
+		"should launch hedged calls with custom schedule": {
+			schedule: func(attempt int, delay time.Duration) time.Duration {
+				return delay + time.Duration(attempt)*ms_5
+			},
+			win:     ms_50,
+			offsets: []time.Duration{ms_0, ms_10 + ms_5, ms_20, ms_20 + ms_5},
+		},
+		"should not launch scheduled hedged calls after the winner": {
+			schedule: ScheduleStagger,
+			win:      ms_20 + ms_5,
+			offsets:  []time.Duration{ms_0, ms_10, ms_20},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			var lock sync.Mutex
+			offsets := make(map[int]time.Duration)
+			start, win := time.Now(), tcase.win
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempt, _ := AttemptFromContext(req.Context())
+				lock.Lock()
+				offsets[attempt] = time.Since(start)
+				lock.Unlock()
+				if attempt == 0 {
+					time.Sleep(win)
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+				}
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			})
+			opts := []TransportOption{
+				WithCalls(3),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_10, http.StatusOK)),
+			}
+			if tcase.schedule != nil {
+				opts = append(opts, WithHedgeSchedule(tcase.schedule))
+			}
+			rt := NewTransport(internal, opts...)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			start = time.Now()
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			time.Sleep(ms_20)
+			lock.Lock()
+			defer lock.Unlock()
+			if len(offsets) != len(tcase.offsets) {
+				t.Fatalf("expected %d attempts but got %v", len(tcase.offsets), offsets)
+			}
+			for attempt, expected := range tcase.offsets {
+				if o := offsets[attempt]; o < expected || o > expected+ms_10 {
+					t.Fatalf("expected attempt %d launched at %v but got %v", attempt, expected, o)
+				}
+			}
+		})
+	}
+}
+
+func TestDailyWindow(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	ttable := map[string]struct {
+		from, to time.Duration
+		loc      *time.Location
+		now      time.Time
+		allowed  bool
+	}{
+		"should allow time within window": {
+			from:    2 * time.Hour,
+			to:      4 * time.Hour,
+			now:     time.Date(2021, 1, 1, 3, 0, 0, 0, time.UTC),
+			allowed: true,
+		},
+		"should not allow time at window end": {
+			from: 2 * time.Hour,
+			to:   4 * time.Hour,
+			now:  time.Date(2021, 1, 1, 4, 0, 0, 0, time.UTC),
+		},
+		"should not allow time within wrapped window gap": {
+			from: 4 * time.Hour,
+			to:   2 * time.Hour,
+			now:  time.Date(2021, 1, 1, 2, 30, 0, 0, time.UTC),
+		},
+		"should allow time after midnight within wrapped window": {
+			from:    4 * time.Hour,
+			to:      2 * time.Hour,
+			now:     time.Date(2021, 1, 1, 1, 59, 0, 0, time.UTC),
+			allowed: true,
+		},
+		"should evaluate window in provided location": {
+			from:    2 * time.Hour,
+			to:      4 * time.Hour,
+			loc:     tokyo,
+			now:     time.Date(2021, 1, 1, 18, 30, 0, 0, time.UTC),
+			allowed: true,
+		},
+		"should not evaluate window in provided time location": {
+			from: 2 * time.Hour,
+			to:   4 * time.Hour,
+			loc:  tokyo,
+			now:  time.Date(2021, 1, 1, 3, 0, 0, 0, time.UTC),
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			if allowed := DailyWindow(tcase.from, tcase.to, tcase.loc)(tcase.now); allowed != tcase.allowed {
+				t.Fatalf("expected window to report %v but got %v", tcase.allowed, allowed)
+			}
+		})
+	}
+}
+
+type thooks struct {
+	Resource
+	hooks int64
+}
+
+func (r *thooks) Hook(req *http.Request) func(*http.Response) {
+	atomic.AddInt64(&r.hooks, 1)
+	return r.Resource.Hook(req)
+}
+
+func TestWithHedgeWindow(t *testing.T) {
+	var hedges int64
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if attempt, _ := AttemptFromContext(req.Context()); attempt == 0 {
+			time.Sleep(ms_10)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		}
+		atomic.AddInt64(&hedges, 1)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	clock := newClock()
+	rs := &thooks{Resource: NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_1, http.StatusOK)}
+	rt := NewTransport(
+		internal,
+		WithCalls(1),
+		WithResources(rs),
+		withHedgeWindow(DailyWindow(4*time.Hour, 2*time.Hour, time.UTC), clock.now),
+	)
+	steps := []struct {
+		now    time.Time
+		hedges int64
+	}{
+		{now: time.Date(2021, 1, 1, 1, 59, 59, 0, time.UTC), hedges: 3},
+		{now: time.Date(2021, 1, 1, 2, 0, 0, 0, time.UTC), hedges: 0},
+		{now: time.Date(2021, 1, 1, 3, 59, 59, 0, time.UTC), hedges: 0},
+		{now: time.Date(2021, 1, 1, 4, 0, 0, 0, time.UTC), hedges: 3},
+	}
+	for _, step := range steps {
+		clock.set(step.now)
+		atomic.StoreInt64(&hedges, 0)
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+		}
+		if h := atomic.LoadInt64(&hedges); h != step.hedges {
+			t.Fatalf("expected %d hedged calls at %v but got %d", step.hedges, step.now, h)
+		}
+	}
+	// requests outside of the window still feed the resource hooks.
+	if h := atomic.LoadInt64(&rs.hooks); h < 12 {
+		t.Fatalf("expected at least 12 resource hooks but got %d", h)
+	}
+}
@@ -0,0 +1,141 @@
+package hedgehog
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithShadowMode makes hedged transport make hedged calls in shadow mode, so hedging effect could be measured safely.
+// In shadow mode matched requests original calls are made exactly as by underlying transport alone and their responses
+// and errors are always returned as is, while hedged calls are still launched once the delay elapses before original call
+// is done. Hedged calls responses are checked, hooked, reported to observers with shadow flag and then drained and closed.
+// Shadow hedged calls respect the same hedged calls limits, budgets, tenant quotas, throttle and limiter
+// as regular hedged calls, are authorized, retargeted and sent through hedge transport, and are canceled by close.
+// Shadow hedged calls keep matched request context values, but are never canceled with it, so they outlive the caller
+// returning right after original call is done, instead each shadow hedged call is limited by its own 30s timeout.
+// Still attempt timeout, capture, replacements, failover and stale cache never apply to shadow hedged calls.
+// Requests with bodies that couldn't be replayed make no hedged calls.
+func WithShadowMode() TransportOption {
+	return func(t *transport) {
+		t.shadow = true
+	}
+}
+
+// shadowRoundTrip makes original http call for the matched request as is, while hedged calls are made in background.
+func (t *transport) shadowRoundTrip(req *http.Request, rs Resource, calls uint64, o override, obs observers) (*http.Response, error) {
+	calls = callsOf(rs, calls)
+	if o.calls > 0 {
+		calls = o.calls
+	}
+	if calls == 0 || !replayable(req) {
+		obs.emit(Event{Kind: EventBypass, Request: req, Resource: rs})
+		return t.internal.RoundTrip(req)
+	}
+	after := rs.After
+	if o.delay > 0 {
+		after = func() <-chan time.Time { return time.After(o.delay) }
+	}
+	recordPrimary(rs)
+	quota := t.tenants.budget(req)
+	if quota != nil {
+		quota.Primary()
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-after():
+		}
+		// shadow hedged calls are tracked by transport lifecycle, so close cancels and awaits them.
+		ctx, cancel := context.WithCancel(detached{Context: req.Context()})
+		release, ok := t.life.track(cancel)
+		if !ok {
+			cancel()
+			return
+		}
+		var wg sync.WaitGroup
+		for attempt := 1; attempt <= int(calls); attempt++ {
+			admitted, ok := t.admit(req, rs, quota)
+			if !ok {
+				obs.emit(Event{Kind: EventHedgeSkipped, Request: req, Resource: rs, Attempt: attempt, Shadow: true})
+				continue
+			}
+			wg.Add(1)
+			go func(attempt int) {
+				defer wg.Done()
+				defer admitted()
+				t.shadowAttempt(ctx, req, rs, attempt, obs)
+			}(attempt)
+		}
+		wg.Wait()
+		cancel()
+		release()
+	}()
+	h := rs.Hook(req)
+	resp, err := t.internal.RoundTrip(req)
+	close(done)
+	if err == nil {
+		t.throttle.refund()
+	}
+	if err == nil && t.check(rs, req, resp) == nil {
+		h(resp)
+	}
+	return resp, err
+}
+
+// defaultShadowTimeout defines max time spent on single shadow hedged call.
+const defaultShadowTimeout = 30 * time.Second
+
+// detached defines context that keeps provided context values, but is never canceled with it and has no deadline.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
+
+// shadowAttempt makes single shadow hedged call for provided request and discards its response.
+func (t *transport) shadowAttempt(ctx context.Context, req *http.Request, rs Resource, attempt int, obs observers) {
+	ctx, cancel := context.WithTimeout(ctx, defaultShadowTimeout)
+	defer cancel()
+	sreq := req.Clone(context.WithValue(ctx, attemptKey{}, attempt))
+	obs.emit(Event{Kind: EventAttemptStart, Request: sreq, Resource: rs, Attempt: attempt, Shadow: true})
+	h := rs.Hook(sreq)
+	start := time.Now()
+	err := replay(sreq)
+	var resp *http.Response
+	if err == nil {
+		err = t.authorize(sreq)
+	}
+	if err == nil {
+		t.retarget(sreq, attempt)
+		t.modify(sreq, attempt)
+		internal := t.internal
+		if t.hedger != nil {
+			internal = t.hedger
+		}
+		resp, err = internal.RoundTrip(sreq)
+	}
+	e := Event{Kind: EventAttemptEnd, Request: sreq, Resource: rs, Attempt: attempt, Shadow: true}
+	if err == nil {
+		e.StatusCode = resp.StatusCode
+		if err = t.check(rs, sreq, resp); err == nil {
+			h(resp)
+		}
+		t.discard(resp)
+	}
+	e.Elapsed, e.Err = time.Since(start), err
+	obs.emit(e)
+}
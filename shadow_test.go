@@ -0,0 +1,187 @@
+package hedgehog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithShadowMode(t *testing.T) {
+	boom := errors.New("upstream failure")
+	ttable := map[string]struct {
+		code int
+		err  error
+	}{
+		"should return original call rejected response as is": {
+			code: http.StatusInternalServerError,
+		},
+		"should return original call error as is": {
+			err: boom,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			shadows := make(chan Event, 2)
+			obs := ObserverFunc(func(e Event) {
+				if e.Kind == EventAttemptEnd {
+					shadows <- e
+				}
+			})
+			var closed int64
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if _, ok := AttemptFromContext(req.Context()); ok {
+					return &http.Response{StatusCode: http.StatusOK, Body: tclosed{Reader: strings.NewReader("shadow"), closed: &closed}, Request: req}, nil
+				}
+				time.Sleep(ms_20)
+				if tcase.err != nil {
+					return nil, tcase.err
+				}
+				return &http.Response{StatusCode: tcase.code, Body: http.NoBody, Request: req}, nil
+			})
+			rt := NewTransport(
+				internal,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+				WithShadowMode(),
+				WithTransportObserver(obs),
+			)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			if elapsed := time.Since(start); elapsed < ms_20 || elapsed > ms_50 {
+				t.Fatalf("expected original call latency %v but got %v", ms_20, elapsed)
+			}
+			if err != tcase.err {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if err == nil && resp.StatusCode != tcase.code {
+				t.Fatalf("expected response code %d but got %d", tcase.code, resp.StatusCode)
+			}
+			// shadow hedged call response is reported and closed.
+			e := <-shadows
+			if !e.Shadow || e.Attempt != 1 || e.StatusCode != http.StatusOK || e.Err != nil {
+				t.Fatalf("expected successful shadow hedged call but got %+v", e)
+			}
+			if c := atomic.LoadInt64(&closed); c != 1 {
+				t.Fatalf("expected shadow hedged call response to be closed but got %d closes", c)
+			}
+			select {
+			case e := <-shadows:
+				t.Fatalf("expected single shadow hedged call but got %+v", e)
+			default:
+			}
+		})
+	}
+}
+
+func TestShadowModeLimits(t *testing.T) {
+	events := make(chan Event, 4)
+	obs := ObserverFunc(func(e Event) {
+		if e.Shadow && (e.Kind == EventAttemptEnd || e.Kind == EventHedgeSkipped) {
+			events <- e
+		}
+	})
+	var hedged int64
+	hedger := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt64(&hedged, 1)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		time.Sleep(ms_10)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	rt := NewTransport(
+		internal,
+		WithCalls(2),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+		WithShadowMode(),
+		WithMaxConcurrentHedges(1),
+		WithHedgeTransport(hedger),
+		WithTransportObserver(obs),
+	)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	// second shadow hedged call exceeds concurrent hedged calls limit.
+	if e := <-events; e.Kind != EventHedgeSkipped || e.Attempt != 2 {
+		t.Fatalf("expected skipped second shadow hedged call but got %+v", e)
+	}
+	if h := atomic.LoadInt64(&hedged); h != 1 {
+		t.Fatalf("expected single shadow hedged call through hedge transport but got %d", h)
+	}
+	// close cancels and awaits outstanding shadow hedged calls.
+	ctx, cancel := context.WithTimeout(context.Background(), ms_10)
+	defer cancel()
+	if err := CloseTransport(ctx, rt); err != context.DeadlineExceeded {
+		t.Fatalf("expected err %v but got %v", context.DeadlineExceeded, err)
+	}
+	select {
+	case e := <-events:
+		if e.Kind != EventAttemptEnd || e.Attempt != 1 || e.Err != context.Canceled {
+			t.Fatalf("expected canceled shadow hedged call but got %+v", e)
+		}
+	case <-time.After(ms_50):
+		t.Fatalf("expected shadow hedged call to be canceled by close")
+	}
+}
+
+func TestShadowModeDetached(t *testing.T) {
+	type tkey struct{}
+	events := make(chan Event, 1)
+	obs := ObserverFunc(func(e Event) {
+		if e.Shadow && e.Kind == EventAttemptEnd {
+			events <- e
+		}
+	})
+	hedger := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if v, _ := req.Context().Value(tkey{}).(string); v != "value" {
+			return nil, errors.New("missing request context value")
+		}
+		if _, ok := req.Context().Deadline(); !ok {
+			return nil, errors.New("missing shadow hedged call timeout")
+		}
+		select {
+		case <-time.After(ms_50):
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	})
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		time.Sleep(ms_10)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	rt := NewTransport(
+		internal,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+		WithShadowMode(),
+		WithHedgeTransport(hedger),
+		WithTransportObserver(obs),
+	)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), tkey{}, "value"))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/profile", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	_ = resp.Body.Close()
+	// caller is done with the request, while shadow hedged call is still in flight.
+	cancel()
+	select {
+	case e := <-events:
+		if e.Attempt != 1 || e.StatusCode != http.StatusOK || e.Err != nil {
+			t.Fatalf("expected successful shadow hedged call but got %+v", e)
+		}
+	case <-time.After(ms_100):
+		t.Fatalf("expected shadow hedged call to be reported")
+	}
+}
@@ -0,0 +1,167 @@
+package hedgehog
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrTransportClosed defines hedged transport error that is returned for http calls canceled by transport `Close`.
+type ErrTransportClosed struct{}
+
+func (err ErrTransportClosed) Error() string {
+	return "hedged call canceled: transport is closed"
+}
+
+type lifecycle struct {
+	closed   int32
+	canceled int32
+	paused   int32
+	lock     sync.Mutex
+	wg       sync.WaitGroup
+	seq      uint64
+	cancels  map[uint64]func()
+}
+
+// track registers hedged request with provided attempts cancel function and returns its release function,
+// once transport is closed no more requests are registered, nil lifecycle registers every request.
+func (l *lifecycle) track(cancel func()) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed != 0 {
+		return nil, false
+	}
+	if l.cancels == nil {
+		l.cancels = make(map[uint64]func())
+	}
+	l.seq++
+	id := l.seq
+	l.cancels[id] = cancel
+	l.wg.Add(1)
+	return func() {
+		l.lock.Lock()
+		delete(l.cancels, id)
+		l.lock.Unlock()
+		l.wg.Done()
+	}, true
+}
+
+// enter registers single http call that couldn't be canceled, so it's only awaited by close,
+// unlike track it never allocates, once transport is closed no more calls are registered.
+func (l *lifecycle) enter() bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed != 0 {
+		return false
+	}
+	l.wg.Add(1)
+	return true
+}
+
+// leave releases single http call registered by enter.
+func (l *lifecycle) leave() {
+	if l != nil {
+		l.wg.Done()
+	}
+}
+
+// closing returns whether transport is closed, nil lifecycle is never closed.
+func (l *lifecycle) closing() bool {
+	return l != nil && atomic.LoadInt32(&l.closed) != 0
+}
+
+// suspended returns whether transport hedging is paused, nil lifecycle is never paused.
+func (l *lifecycle) suspended() bool {
+	return l != nil && atomic.LoadInt32(&l.paused) != 0
+}
+
+// aborted returns whether outstanding http calls were canceled by transport close, nil lifecycle is never aborted.
+func (l *lifecycle) aborted() bool {
+	return l != nil && atomic.LoadInt32(&l.canceled) != 0
+}
+
+// Close gracefully shuts hedged transport down: no more hedged calls are made and hedged requests
+// are simply passed to underlying transport, then it waits for all outstanding original and hedged
+// http calls to finish, including calls that are canceled and drained in background. If provided context
+// is done first, outstanding http calls are canceled with `ErrTransportClosed` and the context error is returned.
+// Single http calls of transport without hedged calls are awaited as well, yet they are never canceled.
+// Note that underlying transport itself is never closed.
+func (t *transport) Close(ctx context.Context) error {
+	l := t.life
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	atomic.StoreInt32(&l.closed, 1)
+	l.lock.Unlock()
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	atomic.StoreInt32(&l.canceled, 1)
+	l.lock.Lock()
+	for _, cancel := range l.cancels {
+		cancel()
+	}
+	l.lock.Unlock()
+	return ctx.Err()
+}
+
+// CloseTransport closes provided hedged transport, see `Close`.
+// If provided round tripper is not a hedged transport it does nothing.
+func CloseTransport(ctx context.Context, rt http.RoundTripper) error {
+	t, ok := rt.(*transport)
+	if !ok {
+		return nil
+	}
+	return t.Close(ctx)
+}
+
+// Pause pauses hedged transport hedging until it's resumed, so it could be stopped right away e.g. during incidents,
+// while paused all requests are simply passed to underlying transport as if no resources matched them.
+// Requests that are already in flight are not affected. It's safe to call concurrently with in flight requests.
+func (t *transport) Pause() {
+	if t.life != nil {
+		atomic.StoreInt32(&t.life.paused, 1)
+	}
+}
+
+// Resume resumes hedged transport hedging paused by `Pause`.
+func (t *transport) Resume() {
+	if t.life != nil {
+		atomic.StoreInt32(&t.life.paused, 0)
+	}
+}
+
+// PauseTransport pauses provided hedged transport hedging, see `Pause`, e.g. `PauseTransport(client.Transport)`.
+// If provided round tripper is not a hedged transport it returns false.
+func PauseTransport(rt http.RoundTripper) bool {
+	t, ok := rt.(*transport)
+	if ok {
+		t.Pause()
+	}
+	return ok
+}
+
+// ResumeTransport resumes provided hedged transport hedging, see `Resume`.
+// If provided round tripper is not a hedged transport it returns false.
+func ResumeTransport(rt http.RoundTripper) bool {
+	t, ok := rt.(*transport)
+	if ok {
+		t.Resume()
+	}
+	return ok
+}
@@ -0,0 +1,192 @@
+package hedgehog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransportClose(t *testing.T) {
+	ttable := map[string]struct {
+		block   bool
+		timeout time.Duration
+		err     error
+		callErr error
+	}{
+		"should wait for outstanding hedged calls to finish": {
+			timeout: time.Second,
+		},
+		"should cancel outstanding calls once context is done": {
+			block:   true,
+			timeout: ms_20,
+			err:     context.DeadlineExceeded,
+			callErr: ErrTransportClosed{},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			baseline := runtime.NumGoroutine()
+			var calls int64
+			block := tcase.block
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt64(&calls, 1)
+				attempt, ok := AttemptFromContext(req.Context())
+				switch {
+				case block && ok:
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				case attempt > 0:
+					// hedged calls lose, yet they keep running for a while after they are canceled.
+					time.Sleep(ms_50)
+					return nil, req.Context().Err()
+				}
+				time.Sleep(ms_5)
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+			})
+			rt := NewRoundTripper(internal, 2, NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK))
+			var wg sync.WaitGroup
+			errs := make(chan error, 20)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+					resp, err := rt.RoundTrip(req)
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					errs <- err
+				}()
+			}
+			if !block {
+				wg.Wait()
+			}
+			time.Sleep(ms_10)
+			ctx, cancel := context.WithTimeout(context.Background(), tcase.timeout)
+			defer cancel()
+			start := time.Now()
+			if err := CloseTransport(ctx, rt); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected close err %v but got %v", tcase.err, err)
+			}
+			if elapsed := time.Since(start); elapsed < ms_20 || elapsed > tcase.timeout+ms_50 {
+				t.Fatalf("expected close to wait for outstanding calls within %v but took %v", tcase.timeout, elapsed)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if !errors.Is(err, tcase.callErr) {
+					t.Fatalf("expected call err %v but got %v", tcase.callErr, err)
+				}
+			}
+			// no goroutines are left behind once outstanding calls are done.
+			for i := 0; i < 100 && runtime.NumGoroutine() > baseline; i++ {
+				time.Sleep(ms_1)
+			}
+			if n := runtime.NumGoroutine(); n > baseline {
+				t.Fatalf("expected at most %d goroutines after close but got %d", baseline, n)
+			}
+			// closed transport simply passes requests to underlying transport.
+			atomic.StoreInt64(&calls, 0)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			_ = resp.Body.Close()
+			if c := atomic.LoadInt64(&calls); c != 1 {
+				t.Fatalf("expected exactly 1 http call after close but got %d", c)
+			}
+		})
+	}
+}
+
+func TestTransportCloseSingle(t *testing.T) {
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		time.Sleep(ms_50)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	rt := NewRoundTripper(internal, 0, NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK))
+	errs := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+		_, err := rt.RoundTrip(req)
+		errs <- err
+	}()
+	time.Sleep(ms_10)
+	start := time.Now()
+	if err := CloseTransport(context.Background(), rt); err != nil {
+		t.Fatalf("expected nil close err but got %v", err)
+	}
+	// outstanding single http call is awaited by close.
+	if elapsed := time.Since(start); elapsed < ms_20 {
+		t.Fatalf("expected close to wait for outstanding single call but took %v", elapsed)
+	}
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("expected nil call err but got %v", err)
+		}
+	default:
+		t.Fatalf("expected single call to be done once close returns")
+	}
+}
+
+func TestTransportPause(t *testing.T) {
+	var calls int64
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt64(&calls, 1)
+		select {
+		case <-time.After(ms_10):
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	})
+	client := NewHTTPClient(
+		&http.Client{Transport: internal},
+		ClientWithCalls(2),
+		ClientWithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+	)
+	burst := func() int64 {
+		atomic.StoreInt64(&calls, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get("http://example.com/profile")
+				if err != nil {
+					t.Errorf("expected nil err but got %v", err)
+					return
+				}
+				_ = resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+		return atomic.LoadInt64(&calls)
+	}
+	if c := burst(); c <= 10 {
+		t.Fatalf("expected more than 10 http calls before pause but got %d", c)
+	}
+	if !PauseTransport(client.Transport) {
+		t.Fatal("expected client transport to be paused")
+	}
+	if c := burst(); c != 10 {
+		t.Fatalf("expected exactly 10 http calls while paused but got %d", c)
+	}
+	if !ResumeTransport(client.Transport) {
+		t.Fatal("expected client transport to be resumed")
+	}
+	if c := burst(); c <= 10 {
+		t.Fatalf("expected more than 10 http calls after resume but got %d", c)
+	}
+	if PauseTransport(internal) {
+		t.Fatal("expected non hedged transport not to be paused")
+	}
+}
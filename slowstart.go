@@ -0,0 +1,65 @@
+package hedgehog
+
+import (
+	"time"
+)
+
+// slowStartMultiplier defines initial resource delay multiplier during slow start ramp.
+const slowStartMultiplier = 2.0
+
+type slowStart struct {
+	ramp  time.Duration
+	start time.Time
+	now   func() time.Time
+}
+
+// ResourceWithSlowStart enables slow start ramp for the resource.
+// During provided ramp duration after the resource becomes active (is decorated) hedged calls probability
+// scales linearly from 0 to 1 and resource delay multiplier decays linearly from 2 to 1.
+// Ramp is reset each time the resource is decorated again or replaced.
+func ResourceWithSlowStart(ramp time.Duration) ResourceOption {
+	return resourceWithSlowStart(ramp, time.Now)
+}
+
+func resourceWithSlowStart(ramp time.Duration, now func() time.Time) ResourceOption {
+	return func(r *decorated) {
+		r.slowStart = &slowStart{ramp: ramp, start: now(), now: now}
+	}
+}
+
+func (s *slowStart) progress() float64 {
+	if s.ramp <= 0 {
+		return 1.0
+	}
+	p := float64(s.now().Sub(s.start)) / float64(s.ramp)
+	switch {
+	case p < 0:
+		return 0
+	case p > 1:
+		return 1.0
+	default:
+		return p
+	}
+}
+
+func (s *slowStart) probability() float64 {
+	return s.progress()
+}
+
+func (s *slowStart) multiplier() float64 {
+	return multiplierAt(s.progress())
+}
+
+func (s *slowStart) stats() SlowStartStats {
+	p := s.progress()
+	return SlowStartStats{
+		Active:      p < 1.0,
+		Progress:    p,
+		Probability: p,
+		Multiplier:  multiplierAt(p),
+	}
+}
+
+func multiplierAt(progress float64) float64 {
+	return slowStartMultiplier - (slowStartMultiplier-1.0)*progress
+}
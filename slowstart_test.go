@@ -0,0 +1,151 @@
+package hedgehog
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/1pkg/hedgehog/hedgehogtest"
+)
+
+type tclock struct {
+	lock sync.Mutex
+	t    time.Time
+}
+
+func newClock() *tclock {
+	return &tclock{t: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *tclock) now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.t
+}
+
+func (c *tclock) set(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.t = t
+}
+
+func (c *tclock) advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestResourceWithSlowStart(t *testing.T) {
+	clock := newClock()
+	rs := NewResourceWithOptions(
+		NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_10, http.StatusOK),
+		resourceWithSlowStart(ms_100, clock.now),
+	)
+	rt := NewRoundTripper(http.DefaultTransport, 1, rs)
+	trajectory := []struct {
+		advance time.Duration
+		stats   ResourceStats
+	}{
+		{advance: ms_0, stats: ResourceStats{Delay: ms_20, SlowStart: SlowStartStats{Active: true, Progress: 0, Probability: 0, Multiplier: 2}}},
+		{advance: ms_20, stats: ResourceStats{Delay: ms_10 * 18 / 10, SlowStart: SlowStartStats{Active: true, Progress: 0.2, Probability: 0.2, Multiplier: 1.8}}},
+		{advance: ms_50 - ms_20, stats: ResourceStats{Delay: ms_10 * 15 / 10, SlowStart: SlowStartStats{Active: true, Progress: 0.5, Probability: 0.5, Multiplier: 1.5}}},
+		{advance: ms_50, stats: ResourceStats{Delay: ms_10, SlowStart: SlowStartStats{Active: false, Progress: 1, Probability: 1, Multiplier: 1}}},
+		{advance: ms_100, stats: ResourceStats{Delay: ms_10, SlowStart: SlowStartStats{Active: false, Progress: 1, Probability: 1, Multiplier: 1}}},
+	}
+	for _, step := range trajectory {
+		clock.advance(step.advance)
+		stats, ok := GetStats(rt)
+		if !ok || len(stats.Resources) != 1 {
+			t.Fatalf("expected stats for single resource but got %v", stats)
+		}
+		if !eqstats(step.stats, stats.Resources[0]) {
+			t.Fatalf("expected resource stats %+v but got %+v", step.stats, stats.Resources[0])
+		}
+	}
+	// replacing resource resets its ramp.
+	rs = NewResourceWithOptions(rs)
+	rt = NewRoundTripper(http.DefaultTransport, 1, rs)
+	stats, _ := GetStats(rt)
+	expected := ResourceStats{Delay: ms_20, SlowStart: SlowStartStats{Active: true, Progress: 0, Probability: 0, Multiplier: 2}}
+	if !eqstats(expected, stats.Resources[0]) {
+		t.Fatalf("expected reset resource stats %+v but got %+v", expected, stats.Resources[0])
+	}
+}
+
+func TestResourceWithSlowStartSampling(t *testing.T) {
+	clock := newClock()
+	rs := NewResourceWithOptions(
+		NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_0, http.StatusOK),
+		resourceWithSlowStart(ms_100, clock.now),
+	)
+	rec := newRecorder(ms_1)
+	rt := NewRoundTripper(rec, 1, rs)
+	const requests = 400
+	for _, progress := range []float64{0, 0.5, 1} {
+		clock.set(newClock().now().Add(time.Duration(float64(ms_100) * progress)))
+		rec.calls["/"] = 0
+		for i := 0; i < requests; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+		}
+		hedges := float64(rec.calls["/"]-requests) / requests
+		if math.Abs(hedges-progress) > 0.1 {
+			t.Fatalf("expected hedged ratio ~%.2f but got %.2f", progress, hedges)
+		}
+	}
+}
+
+func eqstats(a, b ResourceStats) bool {
+	const eps = 1e-9
+	return a.Delay == b.Delay &&
+		a.SlowStart.Active == b.SlowStart.Active &&
+		math.Abs(a.SlowStart.Progress-b.SlowStart.Progress) < eps &&
+		math.Abs(a.SlowStart.Probability-b.SlowStart.Probability) < eps &&
+		math.Abs(a.SlowStart.Multiplier-b.SlowStart.Multiplier) < eps
+}
+
+func TestResourceWithSlowStartRandSource(t *testing.T) {
+	ttable := map[string]struct {
+		topts []TransportOption
+		ropts []ResourceOption
+	}{
+		"should sample hedges with transport random source": {
+			topts: []TransportOption{WithRandSource(hedgehogtest.NewSequence(0.1, 0.7, 0.4, 0.9))},
+		},
+		"should sample hedges with resource random source": {
+			topts: []TransportOption{WithRandSource(hedgehogtest.NewSequence(0.9))},
+			ropts: []ResourceOption{ResourceWithRandSource(hedgehogtest.NewSequence(0.1, 0.7, 0.4, 0.9))},
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			clock := newClock()
+			rs := NewResourceWithOptions(
+				NewResourceStatic(http.MethodGet, regexp.MustCompile(``), ms_0, http.StatusOK),
+				append([]ResourceOption{resourceWithSlowStart(ms_100, clock.now)}, tcase.ropts...)...,
+			)
+			clock.advance(ms_50)
+			rec := newRecorder(ms_1)
+			rt := NewTransport(rec, append([]TransportOption{WithCalls(1), WithResources(rs)}, tcase.topts...)...)
+			decisions := make([]bool, 0, 8)
+			for i := 0; i < 8; i++ {
+				calls := rec.calls["/"]
+				req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+				if _, err := rt.RoundTrip(req); err != nil {
+					t.Fatalf("expected nil err but got %v", err)
+				}
+				decisions = append(decisions, rec.calls["/"]-calls > 1)
+			}
+			expected := []bool{true, false, true, false, true, false, true, false}
+			if !reflect.DeepEqual(decisions, expected) {
+				t.Fatalf("expected hedge decisions %v but got %v", expected, decisions)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package hedgehog
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type smoothing struct {
+	maxChange float64
+	interval  time.Duration
+	now       func() time.Time
+	lock      sync.Mutex
+	value     time.Duration
+	at        time.Time
+}
+
+// ResourceWithDelaySmoothing bounds how fast the resource delay might change.
+// Effective delay moves towards the resource delay by at most provided max change fraction of its current value
+// per each elapsed interval, so short lived delay spikes are damped while sustained shifts are still followed.
+// Non positive interval means no smoothing.
+func ResourceWithDelaySmoothing(maxChange float64, interval time.Duration) ResourceOption {
+	return resourceWithDelaySmoothing(maxChange, interval, time.Now)
+}
+
+func resourceWithDelaySmoothing(maxChange float64, interval time.Duration, now func() time.Time) ResourceOption {
+	return func(r *decorated) {
+		r.smoothing = newSmoothing(maxChange, interval, now)
+	}
+}
+
+func newSmoothing(maxChange float64, interval time.Duration, now func() time.Time) *smoothing {
+	return &smoothing{maxChange: math.Abs(maxChange), interval: interval, now: now}
+}
+
+// smooth returns effective delay for provided raw delay.
+func (s *smoothing) smooth(raw time.Duration) time.Duration {
+	if s.interval <= 0 {
+		return raw
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	// there is nothing to smooth against until the first non zero delay.
+	if s.value <= 0 {
+		s.value, s.at = raw, now
+		return s.value
+	}
+	steps := int64(now.Sub(s.at) / s.interval)
+	if steps <= 0 {
+		return s.value
+	}
+	s.at = s.at.Add(time.Duration(steps) * s.interval)
+	lo := time.Duration(float64(s.value) * math.Pow(math.Max(1-s.maxChange, 0), float64(steps)))
+	hi := time.Duration(float64(s.value) * math.Pow(1+s.maxChange, float64(steps)))
+	switch {
+	case raw < lo:
+		s.value = lo
+	case raw > hi:
+		s.value = hi
+	default:
+		s.value = raw
+	}
+	return s.value
+}
+
+type smoothed struct {
+	Delayer
+	smoothing *smoothing
+}
+
+// NewDelayerSmoothed returns new delayer instance that bounds how fast provided delayer delay might change,
+// see `ResourceWithDelaySmoothing` for details.
+func NewDelayerSmoothed(d Delayer, maxChange float64, interval time.Duration) Delayer {
+	return smoothed{Delayer: d, smoothing: newSmoothing(maxChange, interval, time.Now)}
+}
+
+func (d smoothed) Delay() time.Duration {
+	return d.smoothing.smooth(d.Delayer.Delay())
+}
@@ -0,0 +1,49 @@
+package hedgehog
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestResourceWithDelaySmoothing(t *testing.T) {
+	const ms_15, ms_22_5, ms_33_75 = 15 * time.Millisecond, 22500 * time.Microsecond, 33750 * time.Microsecond
+	clock := newClock()
+	raw := []time.Duration{ms_10, ms_50, ms_10, ms_50, ms_10, ms_50, ms_50, ms_50, ms_50, ms_50, ms_50}
+	effective := []time.Duration{ms_10, ms_15, ms_10, ms_15, ms_10, ms_15, ms_22_5, ms_33_75, ms_50, ms_50, ms_50}
+	inner := &tdelays{static: NewResourceStatic("", regexp.MustCompile(``), ms_1, 0).(static), delays: raw}
+	rs := NewResourceWithOptions(
+		inner,
+		ResourceWithName("search"),
+		resourceWithDelaySmoothing(0.5, time.Second, clock.now),
+	)
+	rt := NewRoundTripper(newRecorder(ms_0), 0, rs)
+	for i := range raw {
+		// each hook call moves inner delay to the next raw value.
+		rs.Hook(nil)(nil)
+		if i > 0 {
+			clock.advance(time.Second)
+		}
+		stats, _ := GetStats(rt)
+		rstats := stats.Resources[0]
+		if rstats.RawDelay != raw[i] {
+			t.Fatalf("expected raw delay %v at step %d but got %v", raw[i], i, rstats.RawDelay)
+		}
+		if rstats.Delay != effective[i] {
+			t.Fatalf("expected effective delay %v at step %d but got %v", effective[i], i, rstats.Delay)
+		}
+		// effective delay doesn't change within the same interval.
+		clock.advance(time.Second / 2)
+		if d := rs.(delayer).duration(); d != effective[i] {
+			t.Fatalf("expected effective delay %v within interval at step %d but got %v", effective[i], i, d)
+		}
+		clock.advance(-time.Second / 2)
+	}
+}
+
+func TestDelayerSmoothed(t *testing.T) {
+	d := NewDelayerSmoothed(NewDelayerStatic(ms_10), 0.5, 0)
+	if delay := d.Delay(); delay != ms_10 {
+		t.Fatalf("expected delay %v but got %v", ms_10, delay)
+	}
+}
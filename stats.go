@@ -0,0 +1,165 @@
+package hedgehog
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Stats defines hedged transport stats snapshot,
+// shed is only reported for transports with load shedder and contains number of hedged calls skipped by it.
+type Stats struct {
+	Calls      uint64
+	Resources  []ResourceStats
+	Experiment ExperimentStats
+	Budgets    map[string]BudgetStats
+	Tenants    map[string]BudgetStats
+	Throttle   ThrottleStats
+	Policy     PolicyStats
+	Wins       WinStats
+	Shed       uint64
+}
+
+// ResourceStats defines hedged transport resource stats snapshot.
+// Name is only reported for decorated resources and delay is only reported for resources created by this package,
+// raw delay is the resource delay before it's adjusted by resource options like smoothing or slow start,
+// hits are only reported for resource groups and contain each group matcher hits,
+// violations are only reported for resources with soft check and contain number of failed checks,
+// suppressed are only reported for resources with hedge cooldown and contain number of hedged calls suppressed by it,
+// backoff is only reported for transports with error backoff and contains the resource delay multiplier.
+type ResourceStats struct {
+	Name       string
+	Delay      time.Duration
+	RawDelay   time.Duration
+	Hits       []uint64
+	Violations uint64
+	Suppressed uint64
+	Backoff    float64
+	SlowStart  SlowStartStats
+}
+
+// SlowStartStats defines resource slow start ramp stats snapshot.
+type SlowStartStats struct {
+	Active      bool
+	Progress    float64
+	Probability float64
+	Multiplier  float64
+}
+
+// ExperimentStats defines hedged transport A/B experiment stats snapshot.
+type ExperimentStats struct {
+	ControlFraction float64
+	Treatment       CohortStats
+	Control         CohortStats
+}
+
+// CohortStats defines hedged transport A/B experiment cohort stats snapshot,
+// latency quantiles are calculated over the latest 1024 cohort requests.
+type CohortStats struct {
+	Requests uint64
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+}
+
+// BudgetStats defines hedged calls budget stats snapshot over its sliding window.
+type BudgetStats struct {
+	Name      string
+	MaxRatio  float64
+	Primaries uint64
+	Hedges    uint64
+	Remaining float64
+	Throttled uint64
+}
+
+// ThrottleStats defines hedged transport throttling token bucket stats snapshot,
+// exhausted reports whether hedged calls are currently throttled and throttled is the number of skipped hedged calls.
+type ThrottleStats struct {
+	MaxTokens float64
+	Ratio     float64
+	Tokens    float64
+	Exhausted bool
+	Throttled uint64
+}
+
+// PolicyStats defines hedged transport server policies stats snapshot,
+// active is the number of hosts with valid server policy, applied is the number of requests
+// hedged according to server policy and malformed is the number of ignored malformed policy directives.
+type PolicyStats struct {
+	Active    int
+	Applied   uint64
+	Malformed uint64
+}
+
+// WinStats defines hedged transport winners stats snapshot,
+// primary and hedged are the numbers of original and hedged calls responses returned by the transport,
+// ties are the numbers of original calls responses preferred over hedged calls ones, see `WithPrimaryPreference`.
+type WinStats struct {
+	Primary uint64
+	Hedged  uint64
+	Ties    uint64
+}
+
+// GetStats returns provided hedged transport stats snapshot.
+// If provided round tripper is not a hedged transport it returns false.
+func GetStats(rt http.RoundTripper) (Stats, bool) {
+	t, ok := rt.(*transport)
+	if !ok {
+		return Stats{}, false
+	}
+	stats := Stats{Calls: t.calls, Resources: make([]ResourceStats, 0, len(t.resources))}
+	for i, rs := range t.resources {
+		rstats := resourceStats(rs)
+		if bo := t.backoffOf(i); bo != nil {
+			rstats.Backoff = bo.multiplier()
+		}
+		stats.Resources = append(stats.Resources, rstats)
+		if d, ok := rs.(*decorated); ok && d.budget != nil {
+			if stats.Budgets == nil {
+				stats.Budgets = make(map[string]BudgetStats)
+			}
+			stats.Budgets[d.budget.name] = d.budget.stats()
+		}
+	}
+	if t.experiment != nil {
+		stats.Experiment = t.experiment.stats()
+	}
+	if t.tenants != nil {
+		stats.Tenants = t.tenants.stats()
+	}
+	if t.throttle != nil {
+		stats.Throttle = t.throttle.stats()
+	}
+	if t.policy != nil {
+		stats.Policy = t.policy.stats()
+	}
+	if t.wins != nil {
+		stats.Wins = t.wins.stats()
+	}
+	stats.Shed = t.shedder.count()
+	return stats, true
+}
+
+func resourceStats(rs Resource) ResourceStats {
+	var stats ResourceStats
+	if d, ok := rs.(delayer); ok {
+		stats.Delay = d.duration()
+		stats.RawDelay = stats.Delay
+	}
+	if d, ok := rs.(*decorated); ok {
+		stats.Name = d.name
+		stats.Violations = atomic.LoadUint64(&d.violations)
+		stats.Suppressed = atomic.LoadUint64(&d.suppressed)
+		if inner, ok := d.Resource.(delayer); ok {
+			stats.RawDelay = inner.duration()
+		}
+		if d.slowStart != nil {
+			stats.SlowStart = d.slowStart.stats()
+		}
+		rs = d.Resource
+	}
+	if g, ok := rs.(*group); ok {
+		stats.Hits = g.stats()
+	}
+	return stats
+}
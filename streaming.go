@@ -0,0 +1,51 @@
+package hedgehog
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultStreamingTypes defines default content types of streaming responses.
+var defaultStreamingTypes = []string{"text/event-stream"}
+
+// WithStreamingContentTypes sets hedged transport content types of streaming responses, by default only `text/event-stream`.
+// Streaming responses bodies may never end, so once streaming response wins all other attempts are canceled right away,
+// and responses of streaming content types that are not returned to the caller are closed without being drained.
+// Winning responses of unknown content length with chunked transfer encoding are treated as streaming as well,
+// while such losing responses are still drained up to the drain limit, so their connections could be reused.
+func WithStreamingContentTypes(types ...string) TransportOption {
+	return func(t *transport) {
+		t.streamingTypes = make([]string, 0, len(types))
+		for _, typ := range types {
+			t.streamingTypes = append(t.streamingTypes, strings.ToLower(strings.TrimSpace(typ)))
+		}
+	}
+}
+
+// streaming returns whether provided response is streaming response,
+// either of streaming content type or of unknown content length with chunked transfer encoding.
+func (t *transport) streaming(resp *http.Response) bool {
+	if resp.ContentLength < 0 && len(resp.TransferEncoding) > 0 && resp.TransferEncoding[0] == "chunked" {
+		return true
+	}
+	return t.streamingType(resp)
+}
+
+// streamingType returns whether provided response is of streaming content type.
+func (t *transport) streamingType(resp *http.Response) bool {
+	ct := resp.Header.Get("Content-Type")
+	if ct == "" {
+		return false
+	}
+	ct = strings.ToLower(strings.TrimSpace(strings.SplitN(ct, ";", 2)[0]))
+	types := t.streamingTypes
+	if types == nil {
+		types = defaultStreamingTypes
+	}
+	for _, typ := range types {
+		if ct == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package hedgehog
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamingResponses(t *testing.T) {
+	ttable := map[string]struct {
+		types       []string
+		contentType string
+	}{
+		"should cancel losing event stream right away": {
+			contentType: "text/event-stream; charset=utf-8",
+		},
+		"should cancel losing stream of configured content type right away": {
+			types:       []string{"application/x-ndjson"},
+			contentType: "application/x-ndjson",
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			var lock sync.Mutex
+			canceled := make(map[int]time.Time)
+			// the hedged call stream starts first and is held while the original call stream wins the tie.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt, _ := strconv.Atoi(r.Header.Get("X-Attempt"))
+				if attempt == 0 {
+					time.Sleep(ms_20)
+				}
+				w.Header().Set("Content-Type", tcase.contentType)
+				w.WriteHeader(http.StatusOK)
+				for i := 0; ; i++ {
+					if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+						break
+					}
+					w.(http.Flusher).Flush()
+					select {
+					case <-time.After(ms_10):
+						continue
+					case <-r.Context().Done():
+					}
+					break
+				}
+				lock.Lock()
+				canceled[attempt] = time.Now()
+				lock.Unlock()
+			}))
+			defer server.Close()
+			opts := []TransportOption{
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`events`), ms_1, http.StatusOK)),
+				WithPrimaryPreference(ms_100),
+				WithAttemptModifier(func(attempt int, req *http.Request) {
+					req.Header.Set("X-Attempt", strconv.Itoa(attempt))
+				}),
+			}
+			if tcase.types != nil {
+				opts = append(opts, WithStreamingContentTypes(tcase.types...))
+			}
+			rt := NewTransport(http.DefaultTransport, opts...)
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			defer resp.Body.Close()
+			if elapsed := time.Since(start); elapsed > ms_100 {
+				t.Fatalf("expected streaming response within %v but took %v", ms_100, elapsed)
+			}
+			// the losing stream server side context is canceled within bounded time, as the server
+			// notices closed connection only on its own schedule, it's polled for a while.
+			var at time.Time
+			for ok := false; !ok && time.Since(start) < 4*ms_100; time.Sleep(ms_5) {
+				lock.Lock()
+				at, ok = canceled[1]
+				lock.Unlock()
+			}
+			if at.IsZero() || at.Sub(start) > 4*ms_100 {
+				t.Fatalf("expected losing stream to be canceled within %v", 4*ms_100)
+			}
+			// the winner keeps streaming to the caller.
+			scanner := bufio.NewScanner(resp.Body)
+			for events := 0; events < 5 && scanner.Scan(); {
+				if scanner.Text() != "" {
+					events++
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("expected nil stream err but got %v", err)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if _, ok := canceled[0]; ok {
+				t.Fatal("expected winner stream to keep going")
+			}
+		})
+	}
+}
+
+func TestStreamingResponseTypes(t *testing.T) {
+	ttable := map[string]struct {
+		types     []string
+		resp      *http.Response
+		streaming bool
+	}{
+		"should treat event stream as streaming by default": {
+			resp:      &http.Response{Header: http.Header{"Content-Type": {"Text/Event-Stream; charset=utf-8"}}, ContentLength: -1},
+			streaming: true,
+		},
+		"should not treat event stream as streaming if not configured": {
+			types: []string{"application/x-ndjson"},
+			resp:  &http.Response{Header: http.Header{"Content-Type": {"text/event-stream"}}, ContentLength: -1},
+		},
+		"should treat chunked response of unknown length as streaming": {
+			resp:      &http.Response{Header: http.Header{}, ContentLength: -1, TransferEncoding: []string{"chunked"}},
+			streaming: true,
+		},
+		"should not treat response of known length as streaming": {
+			resp: &http.Response{Header: http.Header{"Content-Type": {"application/json"}}, ContentLength: 42},
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			var tr transport
+			if tcase.types != nil {
+				WithStreamingContentTypes(tcase.types...)(&tr)
+			}
+			if streaming := tr.streaming(tcase.resp); streaming != tcase.streaming {
+				t.Fatalf("expected streaming %v but got %v", tcase.streaming, streaming)
+			}
+		})
+	}
+}
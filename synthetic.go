@@ -0,0 +1,80 @@
+package hedgehog
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// SyntheticHeader defines header that is set on synthetic responses produced by hedged transport, see `WithErrorResponses`.
+const SyntheticHeader = "X-Hedgehog-Synthetic"
+
+type synthetic struct {
+	codes map[error]int
+}
+
+// WithErrorResponses makes hedged transport return synthetic http responses instead of errors for failed matched requests,
+// when all attempts fail with timeout or cancellation errors synthetic gateway timeout response is returned,
+// other errors are still returned as is unless they match provided errors to response codes map, see `errors.Is`.
+// Synthetic response has empty body and carries `SyntheticHeader` header. By default errors are always returned.
+func WithErrorResponses(codes map[error]int) TransportOption {
+	return func(t *transport) {
+		t.synthetic = &synthetic{codes: codes}
+	}
+}
+
+// respond returns synthetic http response for provided request error if it's mapped to any response code.
+func (s *synthetic) respond(req *http.Request, err error) (*http.Response, bool) {
+	if s == nil || err == nil {
+		return nil, false
+	}
+	code := 0
+	for target, c := range s.codes {
+		if errors.Is(err, target) {
+			code = c
+			break
+		}
+	}
+	if code == 0 && timedOut(err) {
+		code = http.StatusGatewayTimeout
+	}
+	if code == 0 {
+		return nil, false
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{SyntheticHeader: {"1"}},
+		Body:          http.NoBody,
+		ContentLength: 0,
+		Request:       req,
+	}, true
+}
+
+// timedOut returns whether provided error is timeout or cancellation error,
+// all attempts errors must be timeout or cancellation errors for hedged exchange error.
+func timedOut(err error) bool {
+	var all ErrAllAttemptsFailed
+	if errors.As(err, &all) && len(all.Errors) > 0 {
+		for _, err := range all.Errors {
+			if !timedOut(err) {
+				return false
+			}
+		}
+		return true
+	}
+	var soft ErrSoftDeadline
+	if errors.As(err, &soft) {
+		return true
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,89 @@
+package hedgehog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWithErrorResponses(t *testing.T) {
+	boom := errors.New("upstream failure")
+	ttable := map[string]struct {
+		fail error
+		opts []TransportOption
+		code int
+		err  error
+	}{
+		"should return timeout errors by default": {
+			opts: []TransportOption{WithAttemptTimeout(ms_10)},
+			err:  ErrAttemptTimeout{Timeout: ms_10},
+		},
+		"should return synthetic gateway timeout response once all attempts time out": {
+			opts: []TransportOption{WithAttemptTimeout(ms_10), WithErrorResponses(nil)},
+			code: http.StatusGatewayTimeout,
+		},
+		"should return non timeout errors as is": {
+			fail: boom,
+			opts: []TransportOption{WithErrorResponses(nil)},
+			err:  boom,
+		},
+		"should return synthetic response for mapped errors": {
+			fail: boom,
+			opts: []TransportOption{WithErrorResponses(map[error]int{boom: http.StatusBadGateway})},
+			code: http.StatusBadGateway,
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			primary := make(chan context.Context, 1)
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if attempt, _ := AttemptFromContext(req.Context()); attempt == 0 {
+					primary <- req.Context()
+				}
+				if tcase.fail != nil {
+					return nil, tcase.fail
+				}
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			})
+			rt := NewTransport(internal, append(
+				tcase.opts,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+			)...)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			resp, err := rt.RoundTrip(req)
+			if !errors.Is(err, tcase.err) || (err == nil) != (tcase.err == nil) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if resp.StatusCode != tcase.code || resp.Header.Get(SyntheticHeader) != "1" || resp.Request != req {
+				t.Fatalf("expected synthetic response with code %d but got %+v", tcase.code, resp)
+			}
+			// synthetic response belongs to no attempt, so the original call is canceled before the body is closed.
+			select {
+			case <-(<-primary).Done():
+			case <-time.After(ms_50):
+				t.Fatal("expected original call to be canceled")
+			}
+			b, err := io.ReadAll(resp.Body)
+			if err != nil || len(b) != 0 || resp.ContentLength != 0 {
+				t.Fatalf("expected synthetic response empty body but got %q %v", b, err)
+			}
+			if err := resp.Body.Close(); err != nil {
+				t.Fatalf("expected synthetic response body to close but got %v", err)
+			}
+			if expected := strconv.Itoa(tcase.code) + " " + http.StatusText(tcase.code); resp.Status != expected {
+				t.Fatalf("expected synthetic response well formed status but got %q", resp.Status)
+			}
+		})
+	}
+}
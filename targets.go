@@ -0,0 +1,47 @@
+package hedgehog
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// WithTargets sets hedged transport targets picker that picks target base url for each original and hedged http call
+// of matched requests by the call attempt launch order index, see `AttemptFromContext`. Each call request url scheme
+// and host are replaced with picked target ones while the path and the query are kept intact, nil target keeps the call
+// on the original url. Request host header follows picked target unless it's explicitly set by the caller.
+// It lets hedged calls go to replicas instead of the possibly struggling original host, see `RoundRobinTargets`.
+func WithTargets(picker func(attempt int, req *http.Request) *url.URL) TransportOption {
+	return func(t *transport) {
+		t.targets = picker
+	}
+}
+
+// RoundRobinTargets returns targets picker that keeps original calls on the original url
+// and spreads hedged calls over provided target base urls in round robin manner, see `WithTargets`.
+func RoundRobinTargets(targets ...*url.URL) func(attempt int, req *http.Request) *url.URL {
+	return func(attempt int, req *http.Request) *url.URL {
+		if attempt == 0 || len(targets) == 0 {
+			return nil
+		}
+		return targets[(attempt-1)%len(targets)]
+	}
+}
+
+// retarget replaces provided request url scheme and host with picked target ones,
+// provided request must be owned by the caller.
+func (t *transport) retarget(req *http.Request, attempt int) {
+	if t.targets == nil {
+		return
+	}
+	target := t.targets(attempt, req)
+	if target == nil {
+		return
+	}
+	u := *req.URL
+	u.Scheme, u.Host = target.Scheme, target.Host
+	// host header that was not set explicitly follows the request url.
+	if req.Host == req.URL.Host {
+		req.Host = ""
+	}
+	req.URL = &u
+}
@@ -0,0 +1,84 @@
+package hedgehog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithTargets(t *testing.T) {
+	var lock sync.Mutex
+	hosts := make(map[string][]string)
+	serve := func(name string, delay time.Duration) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			lock.Lock()
+			hosts[name] = append(hosts[name], req.Host+req.URL.RequestURI())
+			lock.Unlock()
+			select {
+			case <-time.After(delay):
+			case <-req.Context().Done():
+				return
+			}
+			_, _ = io.WriteString(w, name)
+		}))
+	}
+	origin, replica := serve("origin", ms_100), serve("replica", ms_0)
+	defer origin.Close()
+	defer replica.Close()
+	ru, _ := url.Parse(replica.URL)
+	ttable := map[string]struct {
+		host        string
+		winner      string
+		replicaHost string
+	}{
+		"should send hedged call to replica with host following replica url": {
+			winner:      "replica",
+			replicaHost: ru.Host,
+		},
+		"should send hedged call to replica with explicitly set host": {
+			host:        "example.com",
+			winner:      "replica",
+			replicaHost: "example.com",
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			lock.Lock()
+			hosts = make(map[string][]string)
+			lock.Unlock()
+			rt := NewTransport(
+				http.DefaultTransport,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_5, http.StatusOK)),
+				WithTargets(RoundRobinTargets(ru)),
+			)
+			req, _ := http.NewRequest(http.MethodGet, origin.URL+"/profile?id=1", nil)
+			if tcase.host != "" {
+				req.Host = tcase.host
+			}
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("expected nil err but got %v", err)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if string(b) != tcase.winner {
+				t.Fatalf("expected winner %q but got %q", tcase.winner, string(b))
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if len(hosts["origin"]) != 1 {
+				t.Fatalf("expected original url to receive original call but got %v", hosts["origin"])
+			}
+			if expected := tcase.replicaHost + "/profile?id=1"; len(hosts["replica"]) != 1 || hosts["replica"][0] != expected {
+				t.Fatalf("expected replica to receive %q but got %v", expected, hosts["replica"])
+			}
+		})
+	}
+}
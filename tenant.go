@@ -0,0 +1,81 @@
+package hedgehog
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type tenants struct {
+	header  string
+	ratio   float64
+	window  time.Duration
+	max     int
+	now     func() time.Time
+	lock    sync.Mutex
+	shared  *Budget
+	budgets map[string]*list.Element
+	order   *list.List
+}
+
+// WithTenantQuota enables per tenant hedged calls quotas, so single tenant hedged calls can't exhaust hedging for others.
+// Tenant is identified by provided request header value, requests with empty header value share single common quota.
+// Each tenant hedged calls are allowed only while their number stays within provided ratio of the tenant original calls
+// over provided sliding window, hedged calls of tenants over their quota are skipped.
+// Up to provided max tenants quotas are kept, the least recently used tenant quota is dropped on overflow.
+func WithTenantQuota(header string, ratio float64, window time.Duration, maxTenants int) TransportOption {
+	return withTenantQuota(header, ratio, window, maxTenants, time.Now)
+}
+
+func withTenantQuota(header string, ratio float64, window time.Duration, maxTenants int, now func() time.Time) TransportOption {
+	return func(t *transport) {
+		t.tenants = &tenants{
+			header:  http.CanonicalHeaderKey(header),
+			ratio:   ratio,
+			window:  window,
+			max:     maxTenants,
+			now:     now,
+			shared:  newHedgeBudget("", ratio, window, now),
+			budgets: make(map[string]*list.Element),
+			order:   list.New(),
+		}
+	}
+}
+
+// budget returns provided request tenant quota budget, nil tenants have no budgets.
+func (ts *tenants) budget(req *http.Request) *Budget {
+	if ts == nil {
+		return nil
+	}
+	key := req.Header.Get(ts.header)
+	if key == "" {
+		return ts.shared
+	}
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	if el, ok := ts.budgets[key]; ok {
+		ts.order.MoveToFront(el)
+		return el.Value.(*Budget)
+	}
+	b := newHedgeBudget(key, ts.ratio, ts.window, ts.now)
+	ts.budgets[key] = ts.order.PushFront(b)
+	// in case of overflow: just drop the least recently used tenant quota.
+	if ts.max > 0 && ts.order.Len() > ts.max {
+		el := ts.order.Back()
+		ts.order.Remove(el)
+		delete(ts.budgets, el.Value.(*Budget).name)
+	}
+	return b
+}
+
+func (ts *tenants) stats() map[string]BudgetStats {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	stats := make(map[string]BudgetStats, len(ts.budgets)+1)
+	stats[""] = ts.shared.stats()
+	for key, el := range ts.budgets {
+		stats[key] = el.Value.(*Budget).stats()
+	}
+	return stats
+}
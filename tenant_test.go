@@ -0,0 +1,62 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTransportTenantQuota(t *testing.T) {
+	clock := newClock()
+	rec := newRecorder(ms_1)
+	rt := NewTransport(
+		rec,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_0, http.StatusOK)),
+		withTenantQuota("X-Tenant", 0.5, time.Second, 2, clock.now),
+	)
+	call := func(tenant string) {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+		req.Header.Set("X-Tenant", tenant)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+	}
+	hedges := func(tenant string) uint64 {
+		stats, _ := GetStats(rt)
+		return stats.Tenants[tenant].Hedges
+	}
+	call("b")
+	// exhaust tenant a quota.
+	for i := 0; i < 5; i++ {
+		call("a")
+	}
+	if h := hedges("a"); h != 2 {
+		t.Fatalf("expected 2 tenant a hedged calls but got %d", h)
+	}
+	stats, _ := GetStats(rt)
+	if expected := (BudgetStats{Name: "a", MaxRatio: 0.5, Primaries: 5, Hedges: 2, Remaining: 0.5, Throttled: 3}); stats.Tenants["a"] != expected {
+		t.Fatalf("expected tenant a quota stats %+v but got %+v", expected, stats.Tenants["a"])
+	}
+	// tenant b still hedges even though tenant a quota is exhausted.
+	call("b")
+	if h := hedges("b"); h != 1 {
+		t.Fatalf("expected 1 tenant b hedged call but got %d", h)
+	}
+	// requests without tenant share common quota.
+	call("")
+	call("")
+	if h := hedges(""); h != 1 {
+		t.Fatalf("expected 1 shared hedged call but got %d", h)
+	}
+	// the least recently used tenant quota is dropped on overflow.
+	call("c")
+	stats, _ = GetStats(rt)
+	if _, ok := stats.Tenants["a"]; ok || len(stats.Tenants) != 3 {
+		t.Fatalf("expected tenant a quota to be dropped but got %+v", stats.Tenants)
+	}
+	if rec.calls["/profile"] != 10+4 {
+		t.Fatalf("expected %d upstream calls but got %d", 10+4, rec.calls["/profile"])
+	}
+}
@@ -0,0 +1,80 @@
+package hedgehog
+
+import "sync"
+
+type throttle struct {
+	max       float64
+	ratio     float64
+	lock      sync.Mutex
+	tokens    float64
+	throttled uint64
+}
+
+// WithHedgeThrottling enables hedged calls throttling similar to gRPC retry throttling,
+// so hedged calls can't multiply upstream load during upstream latency regressions.
+// Throttling is backed by single transport token bucket that holds up to provided max tokens and starts full,
+// each hedged call consumes one token and each successful original call refunds provided ratio of token,
+// hedged calls are skipped while the bucket is empty and original calls are never throttled.
+// Effectively, in steady state hedged calls stay within provided ratio of successful original calls,
+// e.g. ratio 0.1 allows at most 10% extra http calls on top of max tokens burst.
+// Non positive max tokens means no throttling.
+func WithHedgeThrottling(maxTokens float64, ratio float64) TransportOption {
+	return func(t *transport) {
+		if maxTokens <= 0 {
+			t.throttle = nil
+			return
+		}
+		t.throttle = &throttle{max: maxTokens, ratio: ratio, tokens: maxTokens}
+	}
+}
+
+// allow consumes single token for hedged call if the bucket is not empty, nil throttle allows every hedged call.
+func (th *throttle) allow() bool {
+	if th == nil {
+		return true
+	}
+	th.lock.Lock()
+	defer th.lock.Unlock()
+	if th.tokens < 1 {
+		th.throttled++
+		return false
+	}
+	th.tokens--
+	return true
+}
+
+// restore gives back single token consumed by allow for hedged call that was never made, nil throttle restores nothing.
+func (th *throttle) restore() {
+	if th == nil {
+		return
+	}
+	th.lock.Lock()
+	defer th.lock.Unlock()
+	if th.tokens++; th.tokens > th.max {
+		th.tokens = th.max
+	}
+}
+
+// refund refunds token ratio for successful original call, nil throttle refunds nothing.
+func (th *throttle) refund() {
+	if th == nil {
+		return
+	}
+	th.lock.Lock()
+	defer th.lock.Unlock()
+	if th.tokens += th.ratio; th.tokens > th.max {
+		th.tokens = th.max
+	}
+}
+
+func (th *throttle) stats() ThrottleStats {
+	th.lock.Lock()
+	defer th.lock.Unlock()
+	return ThrottleStats{
+		MaxTokens: th.max,
+		Ratio:     th.ratio,
+		Tokens:    th.tokens,
+		Exhausted: th.tokens < 1,
+		Throttled: th.throttled,
+	}
+}
@@ -0,0 +1,105 @@
+package hedgehog
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithHedgeThrottling(t *testing.T) {
+	var calls int64
+	// upstream latency spike makes every request hedged right away.
+	internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt64(&calls, 1)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(ms_5):
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	rt := NewTransport(
+		internal,
+		WithCalls(1),
+		WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`search`), ms_0, http.StatusOK)),
+		WithHedgeThrottling(5, 0.1),
+	)
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/search", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("expected nil err but got %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+	stats, _ := GetStats(rt)
+	hedges := uint64(atomic.LoadInt64(&calls)) - 100
+	if hedges > 5+10 {
+		t.Fatalf("expected at most %d hedged calls within throttling budget but got %d", 5+10, hedges)
+	}
+	if hedges+stats.Throttle.Throttled != 100 {
+		t.Fatalf("expected %d throttled hedged calls but got %d", 100-hedges, stats.Throttle.Throttled)
+	}
+	if stats.Throttle.MaxTokens != 5 || stats.Throttle.Tokens >= 2 {
+		t.Fatalf("expected drained throttling bucket but got %+v", stats.Throttle)
+	}
+}
+
+func TestHedgeThrottlingRefunds(t *testing.T) {
+	ttable := map[string]struct {
+		calls []uint64
+		codes []int
+	}{
+		"should refund successful original call reaped after hedged call won": {
+			calls: []uint64{1},
+			codes: []int{http.StatusOK},
+		},
+		"should refund successful original call without hedged calls": {
+			calls: []uint64{1, 0},
+			codes: []int{http.StatusInternalServerError, http.StatusOK},
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			var n int64
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if attempt, _ := AttemptFromContext(req.Context()); attempt > 0 {
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+				}
+				time.Sleep(ms_20)
+				i, _ := strconv.Atoi(req.URL.Query().Get("i"))
+				return &http.Response{StatusCode: tcase.codes[i], Body: http.NoBody, Request: req}, nil
+			})
+			rt := NewTransport(
+				internal,
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_1, http.StatusOK)),
+				WithCallsFunc(func(*http.Request, Resource) uint64 {
+					return tcase.calls[atomic.AddInt64(&n, 1)-1]
+				}),
+				WithHedgeThrottling(2, 0.5),
+			)
+			for i := range tcase.calls {
+				req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile?i="+strconv.Itoa(i), nil)
+				if _, err := rt.RoundTrip(req); err != nil {
+					t.Fatalf("expected nil err but got %v", err)
+				}
+			}
+			// single hedged call consumes single token, while single successful original call refunds half of it.
+			var tokens float64
+			for i := 0; i < 100; i++ {
+				stats, _ := GetStats(rt)
+				if tokens = stats.Throttle.Tokens; tokens == 1.5 {
+					break
+				}
+				time.Sleep(ms_1)
+			}
+			if tokens != 1.5 {
+				t.Fatalf("expected %v throttle tokens but got %v", 1.5, tokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package hedgehog
+
+import "net/http"
+
+// TokenSource defines abstract bearer token source, e.g. oauth2 token source adapter.
+// Token source should cache tokens and refresh them only once they are expired,
+// as it's called for each original and hedged http call.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// WithTokenSource sets hedged transport token source that is used to set bearer authorization header
+// on each original and hedged http call of matched requests right at its launch, so hedged calls launched after token refresh
+// never carry stale token. It's needed only when auth transport wraps hedged transport, e.g. oauth2.Transport
+// that sets authorization header once per logical call, when auth transport is wrapped by hedged transport
+// instead each call gets its own token anyway, see `Chain`.
+// If token source fails the call fails with the token source error.
+func WithTokenSource(ts TokenSource) TransportOption {
+	return func(t *transport) {
+		t.tokens = ts
+	}
+}
+
+// authorize sets bearer authorization header on provided request using transport token source,
+// provided request must be owned by the caller.
+func (t *transport) authorize(req *http.Request) error {
+	if t.tokens == nil {
+		return nil
+	}
+	token, err := t.tokens.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
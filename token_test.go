@@ -0,0 +1,81 @@
+package hedgehog
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+type ttokens struct {
+	lock  sync.Mutex
+	token string
+	err   error
+}
+
+func (ts *ttokens) Token() (string, error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	return ts.token, ts.err
+}
+
+func (ts *ttokens) set(token string) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	ts.token = token
+}
+
+func TestWithTokenSource(t *testing.T) {
+	ttable := map[string]struct {
+		err    error
+		tokens []string
+	}{
+		"should authorize each call with then current token": {
+			tokens: []string{"Bearer stale", "Bearer fresh"},
+		},
+		"should fail calls on token source error": {
+			err: errors.New("token expired"),
+		},
+	}
+	for tname, tcase := range ttable {
+		t.Run(tname, func(t *testing.T) {
+			ts := &ttokens{token: "stale", err: tcase.err}
+			var lock sync.Mutex
+			var tokens []string
+			internal := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				lock.Lock()
+				tokens = append(tokens, req.Header.Get("Authorization"))
+				primary := len(tokens) == 1
+				lock.Unlock()
+				if primary {
+					// token is refreshed while the original call is in flight.
+					ts.set("fresh")
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+			})
+			rt := NewTransport(
+				internal,
+				WithCalls(1),
+				WithResources(NewResourceStatic(http.MethodGet, regexp.MustCompile(`profile`), ms_5, http.StatusOK)),
+				WithTokenSource(ts),
+			)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+			_, err := rt.RoundTrip(req)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected err %v but got %v", tcase.err, err)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if !reflect.DeepEqual(tcase.tokens, tokens) {
+				t.Fatalf("expected calls tokens %v but got %v", tcase.tokens, tokens)
+			}
+			if h := req.Header.Get("Authorization"); h != "" {
+				t.Fatalf("expected original request to stay intact but got authorization %q", h)
+			}
+		})
+	}
+}
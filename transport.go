@@ -2,98 +2,930 @@ package hedgehog
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-
-	"golang.org/x/sync/errgroup"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
 )
 
-// NewHTTPClient wraps provided http client with hedged transport.
-// If nil client is provided default client will be used, if nil transport is provided default transport will be used.
-func NewHTTPClient(client *http.Client, calls uint64, resources ...Resource) *http.Client {
-	if client == nil {
-		client = http.DefaultClient
+type transport struct {
+	internal        http.RoundTripper
+	resources       []Resource
+	calls           uint64
+	experiment      *experiment
+	observer        Observer
+	cache           Cache
+	tenants         *tenants
+	throttle        *throttle
+	slots           chan struct{}
+	hosts           *hosts
+	limiter         HedgeLimiter
+	shedder         *shedder
+	window          *window
+	maxLength       int64
+	hedgeUnknown    bool
+	streamingTypes  []string
+	modifier        func(int, *http.Request)
+	stamp           *stamp
+	strip           []string
+	targets         func(int, *http.Request) *url.URL
+	hedger          http.RoundTripper
+	dynamic         func(*http.Request, Resource) uint64
+	shadow          bool
+	dryRun          func(DryRunDecision)
+	synthetic       *synthetic
+	checkUnmodified bool
+	middleware      []func(http.RoundTripper) http.RoundTripper
+	base            http.RoundTripper
+	policy          *policy
+	failover        *failover
+	tokens          TokenSource
+	deadline        time.Duration
+	capture         *capture
+	backoff         *errorBackoff
+	backoffs        []*backoff
+	wins            *wins
+	life            *lifecycle
+	preference      time.Duration
+	drain           int64
+	buffering       int
+	maxBody         int64
+	sanitizer       func(*http.Request) string
+	rand            Rand
+	unsampled       float64
+	index           *index
+	nesting         bool
+	strict          bool
+	raw             bool
+	mergeCookies    bool
+	rejected        bool
+	rank            func(*http.Response) int
+	expect          bool
+	eager           bool
+	replacements    uint64
+	schedule        HedgeSchedule
+	timeout         time.Duration
+	margin          time.Duration
+	collapse        bool
+}
+
+// ErrTransportNested defines hedged transport construction error that is raised when provided transport is already hedged.
+type ErrTransportNested struct {
+	Depth int
+}
+
+func (err ErrTransportNested) Error() string {
+	return fmt.Sprintf(
+		"transport construction failed: provided transport is already hedged at wrapping depth %d, use WithAllowNesting to allow it",
+		err.Depth,
+	)
+}
+
+// ErrTransportCollapse defines hedged transport construction error that is raised when directly wrapped hedged transport
+// couldn't be collapsed as it's configured with provided option that would be lost by collapsing.
+type ErrTransportCollapse struct {
+	Option string
+}
+
+func (err ErrTransportCollapse) Error() string {
+	return fmt.Sprintf(
+		"transport construction failed: wrapped hedged transport option %s would be lost by collapsing, use WithAllowNesting instead",
+		err.Option,
+	)
+}
+
+// ErrAttemptFailed defines hedged transport single http call error, it wraps the call error
+// with the call attempt launch order index, 0 for original call, and the call elapsed time.
+type ErrAttemptFailed struct {
+	Attempt int
+	Elapsed time.Duration
+	Err     error
+}
+
+func (err ErrAttemptFailed) Error() string {
+	return fmt.Sprintf("hedged call attempt %d failed after %v: %v", err.Attempt, err.Elapsed, err.Err)
+}
+
+func (err ErrAttemptFailed) Unwrap() error {
+	return err.Err
+}
+
+// ErrAllAttemptsFailed defines hedged transport error that is returned when all original and hedged http calls fail,
+// it holds every attempt `ErrAttemptFailed` error in the order they occurred and matches any of them with `errors.Is` and `errors.As`.
+type ErrAllAttemptsFailed struct {
+	Attempts int
+	Errors   []error
+}
+
+func (err ErrAllAttemptsFailed) Error() string {
+	msgs := make([]string, 0, len(err.Errors))
+	for _, e := range err.Errors {
+		msgs = append(msgs, e.Error())
+	}
+	return fmt.Sprintf("all %d hedged calls failed: %s", err.Attempts, strings.Join(msgs, "; "))
+}
+
+func (err ErrAllAttemptsFailed) Is(target error) bool {
+	for _, e := range err.Errors {
+		if errors.Is(e, target) {
+			return true
+		}
 	}
-	if client.Transport == nil {
-		client.Transport = http.DefaultTransport
+	return false
+}
+
+func (err ErrAllAttemptsFailed) As(target interface{}) bool {
+	for _, e := range err.Errors {
+		if errors.As(e, target) {
+			return true
+		}
 	}
-	client.Transport = NewRoundTripper(client.Transport, calls, resources...)
-	return client
+	return false
 }
 
-type transport struct {
-	internal  http.RoundTripper
-	resources []Resource
-	calls     uint64
+// attemptsFailed returns aggregated attempts error for provided attempts errors, nil is returned if there are none.
+func attemptsFailed(attempts int, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return ErrAllAttemptsFailed{Attempts: attempts, Errors: errs}
+}
+
+// TransportOption defines hedged transport option.
+type TransportOption func(*transport)
+
+// WithCalls sets hedged transport calls number, see `NewRoundTripper` for zero calls validation only mode.
+func WithCalls(calls uint64) TransportOption {
+	return func(t *transport) {
+		t.calls = calls
+	}
+}
+
+// WithCallsFunc sets hedged transport function that provides hedged calls number for each matched request
+// instead of static hedged calls number, see `WithCalls`. Zero hedged calls number means validation only mode for the request.
+// Per resource and per call hedged calls numbers still take precedence over provided function result.
+func WithCallsFunc(calls func(req *http.Request, rs Resource) uint64) TransportOption {
+	return func(t *transport) {
+		t.dynamic = calls
+	}
+}
+
+// WithHedgeOnFailure makes hedged transport launch hedged calls right away once any http call fails
+// or its response fails resource check, instead of waiting for resource delay to elapse.
+// Hedged calls number, limits and budgets still apply. By default hedged calls always wait for the delay.
+func WithHedgeOnFailure() TransportOption {
+	return func(t *transport) {
+		t.eager = true
+	}
+}
+
+// WithAttemptMiddleware sets hedged transport attempt middlewares that wrap underlying transport (and alternate hedged
+// calls transport if any), so each original and hedged http call goes through them separately with its own context,
+// e.g. metrics middleware then observes each physical http call instead of single logical one. The first provided middleware
+// becomes the outermost one and the last one wraps underlying transport directly, see `Chain`. The chain is built once
+// on the transport creation, while `Unwrap` still returns underlying transport itself.
+func WithAttemptMiddleware(mw ...func(http.RoundTripper) http.RoundTripper) TransportOption {
+	return func(t *transport) {
+		t.middleware = append(t.middleware, mw...)
+	}
+}
+
+// wrap wraps provided transport with hedged transport attempt middlewares.
+func (t *transport) wrap(rt http.RoundTripper) http.RoundTripper {
+	for i := len(t.middleware) - 1; i >= 0; i-- {
+		rt = t.middleware[i](rt)
+	}
+	return rt
+}
+
+// WithHedgeTransport sets hedged transport alternate underlying transport that makes all hedged calls,
+// while original and failover calls are still made by the underlying transport, e.g. separately tuned transport
+// with shorter dial timeout or different proxy. Responses of both transports are checked and hooked the same way.
+// Nil alternate transport means hedged calls are made by the underlying transport.
+func WithHedgeTransport(rt http.RoundTripper) TransportOption {
+	return func(t *transport) {
+		t.hedger = rt
+	}
+}
+
+// WithFailureReplacements sets hedged transport max number of failure replacements per matched request,
+// failure replacement is extra http call launched right away once any http call fails or its response fails resource check,
+// independently from hedged calls launched after resource delay, so the worst case number of http calls made
+// for single matched request is bounded by 1 + hedged calls number + failure replacements number.
+// Failure replacements are subject to the same limits and budgets as hedged calls. By default failed calls are never replaced.
+func WithFailureReplacements(replacements uint64) TransportOption {
+	return func(t *transport) {
+		t.replacements = replacements
+	}
+}
+
+// WithResources appends provided resources to hedged transport resources.
+func WithResources(resources ...Resource) TransportOption {
+	return func(t *transport) {
+		t.resources = append(t.resources, resources...)
+	}
+}
+
+// WithAllowNesting allows hedged transport to wrap already hedged transport.
+// Note that nested hedged transports multiply number of http calls made on each level.
+func WithAllowNesting() TransportOption {
+	return func(t *transport) {
+		t.nesting = true
+	}
+}
+
+// WithCollapseNesting makes hedged transport collapse directly wrapped hedged transport into single hedged transport,
+// that wraps underlying transport of the wrapped one and has resources of both, so http calls are never multiplied.
+// Only wrapped hedged transport resources, including their resource options, survive collapsing and are matched after
+// provided resources, while provided calls number applies to all of them. Wrapped hedged transport configured with any other
+// transport options (e.g. observers, cache, limits or middleware) couldn't be collapsed and panics with `ErrTransportCollapse`,
+// except for its random source that is simply replaced. Hedged transport wrapped by other transports couldn't be collapsed
+// and still panics with `ErrTransportNested`.
+func WithCollapseNesting() TransportOption {
+	return func(t *transport) {
+		t.collapse = true
+	}
+}
+
+// WithoutURLNormalization disables request url normalization before resources matching,
+// so resources are matched against request url exactly as it's provided.
+func WithoutURLNormalization() TransportOption {
+	return func(t *transport) {
+		t.raw = true
+	}
+}
+
+// defaultDrainLimit defines default max number of bytes drained from losing responses bodies.
+const defaultDrainLimit = 4 << 10
+
+// WithDrainLimit sets max number of bytes drained from responses bodies that are not returned to the caller
+// before they are closed, so their connections could be reused, by default up to 4KB are drained.
+// Responses with bodies bigger than the limit are simply closed, while losing responses bodies that couldn't be drained
+// within 100ms are closed together with their canceled calls. Non positive limit means no draining.
+func WithDrainLimit(limit int64) TransportOption {
+	return func(t *transport) {
+		t.drain = limit
+	}
 }
 
 // NewRoundTripper returns new http hedged transport with provided resources.
 // Returned transport makes hedged http calls in case of resource matching http request up to calls+1 times,
 // original http call starts right away and then all hedged calls start together after delay specified by resource.
 // Returned transport processes and returns first successful http response all other requests in flight are canceled,
-// in case all hedged response failed it simply returns first occurred error.
+// in case all hedged response failed it returns `ErrAllAttemptsFailed` with all occurred errors.
+// Zero calls means validation only mode: matched requests make single synchronous http call without any extra goroutines,
+// the response is still checked and hooked by the resource, so the resource keeps learning latencies,
+// and `ErrResourceUnexpectedResponseCode` is returned if the check fails. Transport options that need
+// concurrent processing (observers, cache, failover, soft deadline, etc.) and resources options make matched requests leave this mode.
+// Returned response http call context stays alive until its body is closed, so the body could be read after other calls are canceled.
+// Returned transport is safe to use as `httputil.ReverseProxy` transport, as proxied requests streamed bodies are never hedged
+// and returned response body is never buffered unless `WithStaleCache` is enabled, so it's streamed and flushed as it's received.
+// Returned response is never copied, so its trailers are populated once its body is read exactly as with underlying transport.
 // If no matching resources were found - the transport simply calls underlying transport.
+// Requests with bodies are hedged only if their bodies could be replayed with `GetBody`,
+// each http call then sends its own body copy, otherwise the transport simply calls underlying transport.
+// Protocol upgrade (e.g. websocket) and CONNECT requests are never hedged, the transport simply calls underlying transport,
+// the same applies to requests with `Expect: 100-continue` header unless `WithExpectContinue` is enabled.
+// If provided transport is already hedged (even when wrapped by other transports implementing `Unwrap`),
+// it panics with `ErrTransportNested`.
 func NewRoundTripper(internal http.RoundTripper, calls uint64, resources ...Resource) http.RoundTripper {
-	return transport{internal: internal, calls: calls, resources: resources}
+	return NewTransport(internal, WithCalls(calls), WithResources(resources...))
 }
 
-func (t transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
-	for _, rs := range t.resources {
-		if rs.Match(req) {
-			return t.multiRoundTrip(req, rs)
+// NewTransport returns new http hedged transport configured with provided options.
+// Returned transport behaves exactly as transport returned by `NewRoundTripper`,
+// by default it makes no hedged calls and has no resources.
+// By default resources are matched against request with normalized url (lowercased scheme and host,
+// stripped default port and root path instead of empty path), use `WithoutURLNormalization` option to disable it.
+// If provided transport is already hedged (even when wrapped by other transports implementing `Unwrap`),
+// it panics with `ErrTransportNested` unless `WithAllowNesting` or `WithCollapseNesting` option is provided.
+// Returned transport could be gracefully shut down with `CloseTransport`.
+func NewTransport(internal http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	t := &transport{internal: internal, wins: &wins{}, life: &lifecycle{}, drain: defaultDrainLimit}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if inner, ok := internal.(*transport); ok && t.collapse {
+		if option, ok := inner.configured(); ok {
+			panic(ErrTransportCollapse{Option: option})
+		}
+		t.internal = inner.internal
+		t.resources = append(t.resources, inner.resources...)
+	}
+	switch {
+	case t.rand == nil:
+		t.rand = defaultRand()
+	case t.experiment != nil:
+		t.experiment.rnd = t.rand
+	}
+	if t.backoff != nil {
+		t.backoffs = make([]*backoff, 0, len(t.resources))
+		for range t.resources {
+			t.backoffs = append(t.backoffs, t.backoff.new())
+		}
+	}
+	if len(t.resources) >= indexThreshold {
+		t.index = newIndex(t.resources)
+	}
+	if depth, ok := hedged(t.internal); ok && !t.nesting {
+		panic(ErrTransportNested{Depth: depth})
+	}
+	if len(t.middleware) > 0 {
+		t.base, t.internal = t.internal, t.wrap(t.internal)
+		if t.hedger != nil {
+			t.hedger = t.wrap(t.hedger)
+		}
+	}
+	if t.strict {
+		var issues []ValidationIssue
+		for _, issue := range t.validate() {
+			if issue.Kind != IssueShadowUnknown {
+				issues = append(issues, issue)
+			}
+		}
+		if len(issues) > 0 {
+			panic(ErrTransportInvalid{Issues: issues})
+		}
+	}
+	return t
+}
+
+// collapsible defines hedged transport fields that survive collapsing or are only derived by construction itself.
+var collapsible = map[string]bool{
+	"internal":  true,
+	"resources": true,
+	"calls":     true,
+	"index":     true,
+	"wins":      true,
+	"life":      true,
+	"rand":      true,
+	"drain":     true,
+}
+
+// configured returns the first hedged transport field that is set by transport options and would be lost by collapsing.
+func (t *transport) configured() (string, bool) {
+	if t.drain != defaultDrainLimit {
+		return "drain", true
+	}
+	v := reflect.ValueOf(t).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Name; !collapsible[name] && !v.Field(i).IsZero() {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// maxUnwrapDepth defines max depth of transports wrapping chain that is inspected.
+const maxUnwrapDepth = 64
+
+// hedged walks provided transport wrapping chain and returns depth of the first found hedged transport.
+func hedged(rt http.RoundTripper) (int, bool) {
+	for depth := 0; rt != nil && depth < maxUnwrapDepth; depth++ {
+		if _, ok := rt.(*transport); ok {
+			return depth, true
+		}
+		u, ok := rt.(interface{ Unwrap() http.RoundTripper })
+		if !ok {
+			return 0, false
+		}
+		rt = u.Unwrap()
+	}
+	return 0, false
+}
+
+// Unwrap returns hedged transport underlying transport.
+func (t *transport) Unwrap() http.RoundTripper {
+	if t.base != nil {
+		return t.base
+	}
+	return t.internal
+}
+
+// GetResources returns copy of provided hedged transport resources in their matching order,
+// while the transport calls number is reported by `GetStats`.
+// If provided round tripper is not a hedged transport it returns false.
+func GetResources(rt http.RoundTripper) ([]Resource, bool) {
+	t, ok := rt.(*transport)
+	if !ok {
+		return nil, false
+	}
+	return append([]Resource(nil), t.resources...), true
+}
+
+func (t *transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	o := overrideFrom(req.Context())
+	if o.disable || t.life.closing() || t.life.suspended() {
+		return t.internal.RoundTrip(req)
+	}
+	// fast path: no resources could match the request.
+	if len(t.resources) == 0 && o.observer == nil && t.observer == nil {
+		return t.internal.RoundTrip(req)
+	}
+	obs := observers{list: [2]Observer{t.observer, o.observer}}
+	target := t.target(req)
+	if i, ok := t.match(target); ok {
+		rs := t.resources[i]
+		if obs.enabled() {
+			obs.label = t.label(target, rs)
+		}
+		obs.cohort = t.experiment.assign()
+		obs.emit(Event{Kind: EventMatch, Request: req, Resource: rs})
+		// requests taking over the connection or awaiting continue response are not hedged nor checked.
+		if hijacking(req) || (continued(req) && !t.expect) {
+			obs.emit(Event{Kind: EventBypass, Request: req, Resource: rs})
+			return t.internal.RoundTrip(req)
+		}
+		// dynamic hedged calls number simply replaces the static one for the request.
+		calls := t.calls
+		if t.dynamic != nil {
+			calls = t.dynamic(req, rs)
+		}
+		if t.dryRun != nil {
+			return t.dryRoundTrip(req, rs, calls, o)
+		}
+		if t.shadow {
+			return t.shadowRoundTrip(req, rs, calls, o, obs)
+		}
+		if t.single(rs, calls, o, obs) {
+			return t.singleRoundTrip(req, rs)
+		}
+		// requests with bodies that couldn't be replayed or are too large are never hedged.
+		if t.maxBody > 0 && req.ContentLength > t.maxBody {
+			obs.emit(Event{Kind: EventBypass, Request: req, Resource: rs})
+			return t.internal.RoundTrip(req)
+		}
+		if !replayable(req) {
+			if t.buffering <= 0 {
+				obs.emit(Event{Kind: EventBypass, Request: req, Resource: rs})
+				return t.internal.RoundTrip(req)
+			}
+			buffered, ok, err := buffer(req, t.buffering)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				obs.emit(Event{Kind: EventBypass, Request: req, Resource: rs})
+				return t.internal.RoundTrip(buffered)
+			}
+			req = buffered
 		}
+		return t.multiRoundTrip(t.correlate(req), target, rs, t.backoffOf(i), calls, o, obs)
 	}
+	if obs.enabled() {
+		obs.label = t.label(target, nil)
+	}
+	obs.emit(Event{Kind: EventMatch, Request: req})
 	return t.internal.RoundTrip(req)
 }
 
-func (t transport) multiRoundTrip(req *http.Request, rs Resource) (resp *http.Response, err error) {
-	g, ctx := errgroup.WithContext(req.Context())
-	res := make(chan interface{}, t.calls+1)
-	defer close(res)
-	g.Go(func() error {
-		for i := uint64(0); i < t.calls+1; i++ {
-			select {
-			case r := <-res:
-				switch tr := r.(type) {
-				case *http.Response:
-					resp = tr
-					err = nil
-					// if we got result hard stop execution.
-					return context.Canceled
-				case error:
-					// keep only first occurred error.
-					if err == nil {
-						err = tr
-					}
-				}
-			case <-ctx.Done():
-				err = ctx.Err()
-				// if group was canceled hard stop execution.
-				return context.Canceled
+// admit checks hedged calls limits and budgets for single hedged call of provided request and returns its release function,
+// checks that debit nothing go first, while every debit is refunded once any later check denies the hedged call.
+func (t *transport) admit(req *http.Request, rs Resource, quota *Budget) (release func(), ok bool) {
+	if t.life.closing() || !t.shedder.allow() {
+		return nil, false
+	}
+	var undo []func()
+	deny := func() (func(), bool) {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		return nil, false
+	}
+	reserved, ok := t.reserve(req)
+	if !ok {
+		return deny()
+	}
+	undo = append(undo, reserved)
+	done, abort, ok := acquireHedge(rs)
+	if !ok {
+		return deny()
+	}
+	undo = append(undo, abort)
+	if quota != nil && !quota.Allow() {
+		return deny()
+	}
+	undo = append(undo, quota.refund)
+	if !t.throttle.allow() {
+		return deny()
+	}
+	undo = append(undo, t.throttle.restore)
+	// limiter permits couldn't be refunded, so the limiter is consulted last.
+	if t.limiter != nil && !t.limiter.Allow() {
+		return deny()
+	}
+	return func() {
+		done()
+		reserved()
+	}, true
+}
+
+// reap drains and closes losing attempts responses, losing attempts that are still in flight are canceled first,
+// so they never wait for the rest, then losing attempts responses that are already received are drained
+// within drain timeout before they are canceled as well, so their connections could be reused.
+// Provided losing results are already received and provided kept attempt is never canceled.
+func (t *transport) reap(res <-chan result, pending int, cancels []context.CancelFunc, keep int, losers []result) {
+	ready := losers
+	for done := false; !done && pending > 0; {
+		select {
+		case r := <-res:
+			pending--
+			t.refund(r)
+			ready = append(ready, r)
+		default:
+			done = true
+		}
+	}
+	received := make(map[int]bool, len(ready))
+	for _, r := range ready {
+		received[int(r.attempt)] = true
+	}
+	for attempt, cancel := range cancels {
+		if cancel != nil && attempt != keep && !received[attempt] {
+			cancel()
+		}
+	}
+	for _, r := range ready {
+		cancel := cancels[r.attempt]
+		if int(r.attempt) == keep || cancel == nil {
+			cancel = func() {}
+		}
+		t.reclaim(r.resp, cancel)
+		t.reclaim(r.rejected, cancel)
+		cancel()
+	}
+	for ; pending > 0; pending-- {
+		r := <-res
+		t.refund(r)
+		t.discard(r.resp)
+		t.discard(r.rejected)
+	}
+}
+
+// refund refunds throttle token ratio once provided result turns out to be successful original call.
+func (t *transport) refund(r result) {
+	if r.attempt == 0 && r.resp != nil {
+		t.throttle.refund()
+	}
+}
+
+// defaultDrainTimeout defines max time spent on draining single losing response body.
+const defaultDrainTimeout = 100 * time.Millisecond
+
+// reclaim discards provided losing response, its body drain is bounded by drain timeout
+// after which provided attempt cancel is called, so stalled bodies never block the caller.
+func (t *transport) reclaim(resp *http.Response, cancel context.CancelFunc) {
+	if resp == nil {
+		return
+	}
+	timer := time.AfterFunc(defaultDrainTimeout, cancel)
+	defer timer.Stop()
+	t.discard(resp)
+}
+
+// discard drains up to transport drain limit bytes of provided response body and closes it,
+// so its connection could be reused, responses of streaming content types are closed right away and nil response is ignored.
+func (t *transport) discard(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	if t.drain > 0 && !t.streamingType(resp) {
+		_, _ = io.CopyN(io.Discard, resp.Body, t.drain)
+	}
+	_ = resp.Body.Close()
+}
+
+// errHedgeDone defines internal error of attempts canceled by hedged transport once the outcome is known,
+// it's never returned to the caller, while the caller context cancellation is always returned as is.
+var errHedgeDone = errors.New("hedged call canceled: outcome is already known")
+
+// hijacking returns whether provided request response is expected to take over the connection,
+// as protocol upgrade (e.g. websocket handshake) or tunnel requests do.
+func hijacking(req *http.Request) bool {
+	if req.Method == http.MethodConnect || req.Header.Get("Upgrade") != "" {
+		return true
+	}
+	for _, v := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
 			}
 		}
+	}
+	return false
+}
+
+// fatal returns whether provided attempt error is terminal resource check failure.
+func fatal(err error) bool {
+	var f ErrResourceCheckFatal
+	return errors.As(err, &f)
+}
+
+// replayable returns whether provided request body could be replayed for each attempt.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// replay replaces provided request body with its fresh copy, provided request must be owned by the caller.
+func replay(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
 		return nil
-	})
-	roundTrip := func() error {
-		req := req.Clone(ctx)
-		h := rs.Hook(req)
-		resp, err := t.internal.RoundTrip(req)
-		if err != nil {
-			res <- err
-			return nil
-		}
-		if err := rs.Check(resp); err != nil {
-			res <- err
-			return nil
-		}
-		h(resp)
-		res <- resp
-		return nil
 	}
-	g.Go(roundTrip)
-	<-rs.After()
-	for i := uint64(0); i < t.calls; i++ {
-		g.Go(roundTrip)
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
+
+// cancelBody defines response body that cancels its attempt context once it's closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+type result struct {
+	attempt  uint64
+	resp     *http.Response
+	err      error
+	rejected *http.Response
+	prefix   []byte
+	strict   bool
+}
+
+// match returns the first resource matching provided request position.
+func (t *transport) match(req *http.Request) (int, bool) {
+	if t.index != nil {
+		return t.index.match(t.resources, req)
+	}
+	for i, rs := range t.resources {
+		if rs.Match(req) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// single returns whether the matched request could be processed by a single http call
+// without any transport or resource features involved.
+func (t *transport) single(rs Resource, calls uint64, o override, obs observers) bool {
+	if _, ok := rs.(*decorated); ok {
+		return false
+	}
+	return calls == 0 && o.calls == 0 && t.replacements == 0 && !obs.enabled() && t.experiment == nil && t.cache == nil && t.tenants == nil && t.policy == nil && t.failover == nil && t.tokens == nil && t.modifier == nil && t.stamp == nil && t.strip == nil && t.targets == nil && t.backoff == nil && t.capture == nil && t.timeout == 0 && !t.rejected && t.synthetic == nil && t.deadline == 0 && o.deadline == 0
+}
+
+// singleRoundTrip makes single http call for the matched request,
+// the call is awaited by transport close, yet it's never canceled by it as it's made with request context as is.
+func (t *transport) singleRoundTrip(req *http.Request, rs Resource) (*http.Response, error) {
+	if !t.life.enter() {
+		return t.internal.RoundTrip(req)
+	}
+	defer t.life.leave()
+	h := rs.Hook(req)
+	resp, err := t.internal.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.check(rs, req, resp); err != nil {
+		t.discard(resp)
+		return nil, err
+	}
+	h(resp)
+	t.wins.record(0, false)
+	t.throttle.refund()
+	return resp, nil
+}
+
+// target returns shallow copy of provided request with normalized url that is used for matching, caching and reporting,
+// if provided request url is already normalized the request is returned as is.
+func (t *transport) target(req *http.Request) *http.Request {
+	if t.raw || req.URL == nil || normalURL(req.URL) {
+		return req
+	}
+	target := *req
+	target.URL = normalizeURL(req.URL)
+	return &target
+}
+
+// normalURL returns whether provided url is already normalized.
+func normalURL(u *url.URL) bool {
+	if u.Path == "" && u.Opaque == "" {
+		return false
+	}
+	for _, s := range [2]string{u.Scheme, u.Host} {
+		for i := 0; i < len(s); i++ {
+			if 'A' <= s[i] && s[i] <= 'Z' {
+				return false
+			}
+		}
+	}
+	switch {
+	case u.Scheme == "http" && strings.HasSuffix(u.Host, ":80"):
+		return false
+	case u.Scheme == "https" && strings.HasSuffix(u.Host, ":443"):
+		return false
+	default:
+		return true
+	}
+}
+
+// normalizeURL returns normalized copy of provided url with lowercased scheme and host,
+// stripped default port and root path instead of empty path.
+func normalizeURL(u *url.URL) *url.URL {
+	n := *u
+	n.Scheme = strings.ToLower(u.Scheme)
+	n.Host = strings.ToLower(u.Host)
+	switch {
+	case n.Scheme == "http" && strings.HasSuffix(n.Host, ":80"):
+		n.Host = strings.TrimSuffix(n.Host, ":80")
+	case n.Scheme == "https" && strings.HasSuffix(n.Host, ":443"):
+		n.Host = strings.TrimSuffix(n.Host, ":443")
+	}
+	if n.Path == "" && n.Opaque == "" {
+		n.Path, n.RawPath = "/", ""
+	}
+	return &n
+}
+
+func (t *transport) multiRoundTrip(req, target *http.Request, rs Resource, bo *backoff, calls uint64, o override, obs observers) (resp *http.Response, err error) {
+	calls, delay, after := t.plan(req, target, rs, bo, calls, o, obs)
+	if t.experiment != nil {
+		defer t.experiment.record(obs.cohort, time.Now())
+	}
+	r := &race{
+		t:       t,
+		req:     req,
+		target:  target,
+		rs:      rs,
+		bo:      bo,
+		o:       o,
+		obs:     obs,
+		ctx:     req.Context(),
+		dv:      divergenceOf(rs),
+		calls:   calls,
+		res:     make(chan result, calls+t.replacements+1),
+		cancels: make([]context.CancelFunc, calls+t.replacements+1),
+		base:    delay,
+		next:    1,
+		stop:    o.control.stopped(),
+	}
+	release, ok := t.life.track(r.cancel)
+	if !ok {
+		return t.internal.RoundTrip(req)
+	}
+	// original request body is never sent as each attempt sends its own replayed copy instead.
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	obs.emit(Event{Kind: EventDelay, Request: req, Resource: rs, Delay: delay})
+	recordPrimary(rs)
+	r.quota = t.tenants.budget(req)
+	if r.quota != nil {
+		r.quota.Primary()
+	}
+	r.start = time.Now()
+	go r.call(0, r.launch(0))
+	r.pending = 1
+	select {
+	case <-r.stop:
+		r.stop, r.stopped = nil, true
+	default:
+	}
+	switch {
+	case calls > 0 && o.force:
+		// forced hedged calls are all launched right away racing the original call.
+		r.hedges(true)
+	case calls > 0:
+		r.hedge = after()
+	}
+	r.deadline = t.deadline
+	if o.deadline > 0 {
+		r.deadline = o.deadline
+	}
+	if r.deadline > 0 {
+		r.soft = time.After(r.deadline)
+	}
+	r.collect()
+	resp, err = r.resp, r.err
+	winner := r.winner
+	var stale, synthesized bool
+	if resp == nil && err == nil {
+		err = attemptsFailed(r.launched, r.errs)
+	}
+	if t.mergeCookies && resp != nil {
+		r.merge(resp)
+	}
+	if r.dv != nil {
+		r.dv.verify(target.Method, t.label(target, rs), r.succeeded)
+	}
+	// cancel and reap all losing attempts in background once the outcome is reported,
+	// while the winner attempt is canceled only once its response body is closed.
+	defer func() {
+		keep := -1
+		// stale and synthetic responses belong to no attempt, so no attempt is kept for them.
+		if resp != nil && !stale && !synthesized && !IsFailover(resp) {
+			keep = int(winner)
+		}
+		r.reap(resp, keep, release)
+	}()
+	if resp == nil && !r.expired && !r.terminal {
+		if fresp, ok := t.failover.roundTrip(t, req, rs, int(calls+t.replacements)+1, err, obs); ok {
+			resp, err, winner = fresp, nil, calls+1
+		}
+	}
+	switch {
+	case r.rejected != nil && resp == nil:
+		resp, err, winner = r.rejected, nil, r.rejectedAttempt
+	case r.rejected != nil:
+		r.losers = append(r.losers, result{attempt: r.rejectedAttempt, rejected: r.rejected})
+	}
+	if t.cache != nil && cacheable(req) {
+		key := cacheKey(target)
+		switch {
+		case resp != nil && r.strict && resp.StatusCode != http.StatusNotModified:
+			t.store(key, req, resp)
+		case resp == nil && !r.terminal:
+			if c, ok := t.cache.Get(key); ok {
+				resp, err, stale = staleResponse(req, c), nil, true
+				obs.emit(Event{Kind: EventStale, Request: req, Resource: rs, StatusCode: resp.StatusCode})
+				return
+			}
+		}
+	}
+	if resp != nil {
+		t.policy.update(target, resp)
+		if !IsFailover(resp) {
+			t.wins.record(winner, r.tie)
+		}
+		obs.emit(Event{Kind: EventWinner, Request: req, Resource: rs, Attempt: int(winner), StatusCode: resp.StatusCode, Failover: IsFailover(resp), Tie: r.tie})
+	} else {
+		obs.emit(Event{Kind: EventFailure, Request: req, Resource: rs, Err: err})
+		if synth, ok := t.synthetic.respond(req, err); ok {
+			resp, err, synthesized = synth, nil, true
+		}
 	}
-	_ = g.Wait()
 	return
 }
+
+// plan returns matched request hedged calls number, its delay and the delay timer.
+// Resource delay is estimated only once per request, so backoff, deadline skip, schedule and the timer agree on it,
+// while resources that couldn't report their delay are still awaited via their own timer.
+func (t *transport) plan(req, target *http.Request, rs Resource, bo *backoff, calls uint64, o override, obs observers) (uint64, time.Duration, func() <-chan time.Time) {
+	calls, after, delay := callsOf(rs, calls), rs.After, time.Duration(0)
+	if sp, ok := t.policy.lookup(target); ok {
+		// server policy could only lower configured hedged calls number.
+		if sp.calls != nil && *sp.calls < calls {
+			calls = *sp.calls
+		}
+		delay = sp.delay
+	}
+	if d, ok := rs.(delayer); ok && delay == 0 && o.delay == 0 {
+		delay = d.duration()
+	}
+	if m := bo.multiplier(); m > 1 {
+		delay = time.Duration(float64(delay) * m)
+	}
+	if o.calls > 0 {
+		calls = o.calls
+	}
+	if o.delay > 0 {
+		delay = o.delay
+	}
+	if delay > 0 {
+		after = func() <-chan time.Time { return time.After(delay) }
+	}
+	if !sampleHedge(rs, t.rand) {
+		calls = 0
+	}
+	if calls > 0 && !t.sample() {
+		calls = 0
+	}
+	if calls > 0 && !t.window.allow() {
+		calls = 0
+	}
+	if calls > 0 && t.oversized(requestLength(req)) {
+		calls = 0
+	}
+	// hedged calls that couldn't be launched before request deadline are never made.
+	if dl, ok := req.Context().Deadline(); ok && calls > 0 && !o.force {
+		if delay > 0 && time.Until(dl) < delay+t.margin {
+			calls = 0
+		}
+	}
+	if t.experiment != nil && obs.cohort == CohortControl {
+		calls = 0
+	}
+	return calls, delay, after
+}
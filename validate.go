@@ -0,0 +1,243 @@
+package hedgehog
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// ValidationIssueKind defines hedged transport resources validation issue kind.
+type ValidationIssueKind int
+
+// Hedged transport resources validation issue kinds.
+const (
+	// IssueShadowed means the resource can never match as earlier resource matches all of its requests.
+	IssueShadowed ValidationIssueKind = iota
+	// IssueShadowUnknown means the resource might be shadowed by earlier resource but it can't be determined.
+	IssueShadowUnknown
+	// IssueNoAllowedCodes means the resource has no allowed codes so its responses always fail the check.
+	IssueNoAllowedCodes
+	// IssueDuplicateName means the resource has the same name as earlier resource.
+	IssueDuplicateName
+)
+
+// ValidationIssue defines hedged transport resources validation issue,
+// resource indexes are positions of resources in the transport, other resource index is -1 when not applicable.
+type ValidationIssue struct {
+	Kind     ValidationIssueKind
+	Resource int
+	Other    int
+	Message  string
+}
+
+// ErrTransportInvalid defines hedged transport construction error that is raised when strict validation fails.
+type ErrTransportInvalid struct {
+	Issues []ValidationIssue
+}
+
+func (err ErrTransportInvalid) Error() string {
+	msgs := make([]string, 0, len(err.Issues))
+	for _, issue := range err.Issues {
+		msgs = append(msgs, issue.Message)
+	}
+	return fmt.Sprintf("transport construction failed: invalid resources: %s", strings.Join(msgs, "; "))
+}
+
+// WithStrictValidation makes hedged transport construction panic with `ErrTransportInvalid`
+// when validation finds any issues except `IssueShadowUnknown`, see `Validate`.
+func WithStrictValidation() TransportOption {
+	return func(t *transport) {
+		t.strict = true
+	}
+}
+
+// Validate analyzes provided hedged transport resources and returns found issues:
+// - resources shadowed by earlier resources with the same method and broader url pattern
+// - resources with empty allowed codes
+// - resources with duplicate names
+// Url patterns containment is only determined for literal, prefix, exact and catch-all patterns,
+// for any other patterns possible shadowing is reported as `IssueShadowUnknown`.
+// Only resources created by this package are analyzed, if provided round tripper is not a hedged transport it returns nil.
+func Validate(rt http.RoundTripper) []ValidationIssue {
+	t, ok := rt.(*transport)
+	if !ok {
+		return nil
+	}
+	return t.validate()
+}
+
+func (t *transport) validate() []ValidationIssue {
+	var issues []ValidationIssue
+	names := make(map[string]int, len(t.resources))
+	for i, rs := range t.resources {
+		if d, ok := rs.(*decorated); ok && d.name != "" {
+			if j, ok := names[d.name]; ok {
+				issues = append(issues, ValidationIssue{
+					Kind:     IssueDuplicateName,
+					Resource: i,
+					Other:    j,
+					Message:  fmt.Sprintf("resource %d has the same name %q as resource %d", i, d.name, j),
+				})
+			} else {
+				names[d.name] = i
+			}
+		}
+		st, ok := staticOf(rs)
+		if !ok {
+			continue
+		}
+		if len(st.codes) == 0 {
+			issues = append(issues, ValidationIssue{
+				Kind:     IssueNoAllowedCodes,
+				Resource: i,
+				Other:    -1,
+				Message:  fmt.Sprintf("resource %d has no allowed codes", i),
+			})
+		}
+		cur := patternOf(st.url)
+		unknown := -1
+	scan:
+		for j := 0; j < i; j++ {
+			prev, ok := staticOf(t.resources[j])
+			if !ok || prev.method != st.method {
+				continue
+			}
+			switch shadows(patternOf(prev.url), cur) {
+			case shadowYes:
+				issues = append(issues, ValidationIssue{
+					Kind:     IssueShadowed,
+					Resource: i,
+					Other:    j,
+					Message:  fmt.Sprintf("resource %d is shadowed by resource %d", i, j),
+				})
+				unknown = -1
+				break scan
+			case shadowUnknown:
+				if unknown < 0 {
+					unknown = j
+				}
+			}
+		}
+		if unknown >= 0 {
+			issues = append(issues, ValidationIssue{
+				Kind:     IssueShadowUnknown,
+				Resource: i,
+				Other:    unknown,
+				Message:  fmt.Sprintf("resource %d might be shadowed by resource %d", i, unknown),
+			})
+		}
+	}
+	return issues
+}
+
+func staticOf(rs Resource) (static, bool) {
+	if d, ok := rs.(*decorated); ok {
+		rs = d.Resource
+	}
+	b, ok := rs.(interface{ base() static })
+	if !ok {
+		return static{}, false
+	}
+	return b.base(), true
+}
+
+type patternKind int
+
+const (
+	patternUnknown patternKind = iota
+	patternAll
+	patternContains
+	patternPrefix
+	patternExact
+)
+
+type pattern struct {
+	kind    patternKind
+	literal string
+}
+
+func patternOf(url *regexp.Regexp) pattern {
+	if url == nil {
+		return pattern{kind: patternAll}
+	}
+	re, err := syntax.Parse(url.String(), syntax.Perl)
+	if err != nil {
+		return pattern{}
+	}
+	re = re.Simplify()
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	begin, end := false, false
+	if len(subs) > 0 && subs[0].Op == syntax.OpBeginText {
+		begin, subs = true, subs[1:]
+	}
+	if len(subs) > 0 && subs[len(subs)-1].Op == syntax.OpEndText {
+		end, subs = true, subs[:len(subs)-1]
+	}
+	if len(subs) > 0 && anything(subs[len(subs)-1]) {
+		end, subs = false, subs[:len(subs)-1]
+	}
+	if len(subs) > 0 && anything(subs[0]) {
+		begin, subs = false, subs[1:]
+	}
+	literal := ""
+	switch {
+	case len(subs) == 0 || (len(subs) == 1 && subs[0].Op == syntax.OpEmptyMatch):
+	case len(subs) == 1 && subs[0].Op == syntax.OpLiteral && subs[0].Flags&syntax.FoldCase == 0:
+		literal = string(subs[0].Rune)
+	default:
+		return pattern{}
+	}
+	switch {
+	case begin && end:
+		return pattern{kind: patternExact, literal: literal}
+	case begin:
+		return pattern{kind: patternPrefix, literal: literal}
+	case end:
+		return pattern{}
+	case literal == "":
+		return pattern{kind: patternAll}
+	default:
+		return pattern{kind: patternContains, literal: literal}
+	}
+}
+
+func anything(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpStar &&
+		(re.Sub[0].Op == syntax.OpAnyChar || re.Sub[0].Op == syntax.OpAnyCharNotNL)
+}
+
+type shadow int
+
+const (
+	shadowNo shadow = iota
+	shadowYes
+	shadowUnknown
+)
+
+// shadows checks whether all strings matched by later pattern are matched by earlier pattern.
+func shadows(earlier, later pattern) shadow {
+	if earlier.kind == patternAll {
+		return shadowYes
+	}
+	if earlier.kind == patternUnknown || later.kind == patternUnknown {
+		return shadowUnknown
+	}
+	var ok bool
+	switch earlier.kind {
+	case patternContains:
+		ok = later.kind != patternAll && strings.Contains(later.literal, earlier.literal)
+	case patternPrefix:
+		ok = (later.kind == patternPrefix || later.kind == patternExact) && strings.HasPrefix(later.literal, earlier.literal)
+	case patternExact:
+		ok = later.kind == patternExact && later.literal == earlier.literal
+	}
+	if ok {
+		return shadowYes
+	}
+	return shadowNo
+}
@@ -0,0 +1,96 @@
+package hedgehog
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	get := func(url string, codes ...int) Resource {
+		if len(codes) == 0 {
+			codes = []int{http.StatusOK}
+		}
+		return NewResourceStatic(http.MethodGet, regexp.MustCompile(url), ms_1, codes...)
+	}
+	ttable := map[string]struct {
+		res    []Resource
+		issues []ValidationIssue
+	}{
+		"should not report non overlapping resources": {
+			res: []Resource{get(`^https://api\.example\.com/users$`), get(`^https://api\.example\.com/profile`)},
+		},
+		"should not report resources with different methods": {
+			res: []Resource{
+				NewResourceStatic(http.MethodPost, regexp.MustCompile(`.*`), ms_1, http.StatusOK),
+				get(`^https://api\.example\.com/users$`),
+			},
+		},
+		"should report exact resource shadowed by catch all": {
+			res: []Resource{get(`.*`), get(`^https://api\.example\.com/users$`)},
+			issues: []ValidationIssue{
+				{Kind: IssueShadowed, Resource: 1, Other: 0, Message: "resource 1 is shadowed by resource 0"},
+			},
+		},
+		"should report resources shadowed by literals and prefixes": {
+			res: []Resource{
+				get(`users`),
+				get(`^https://api\.example\.com/`),
+				get(`/api/users/me`),
+				get(`^https://api\.example\.com/profile$`),
+				get(`^https://api\.example\.com/profile/.*`),
+				NewResourceStatic(http.MethodGet, nil, ms_1, http.StatusOK),
+			},
+			issues: []ValidationIssue{
+				{Kind: IssueShadowed, Resource: 2, Other: 0, Message: "resource 2 is shadowed by resource 0"},
+				{Kind: IssueShadowed, Resource: 3, Other: 1, Message: "resource 3 is shadowed by resource 1"},
+				{Kind: IssueShadowed, Resource: 4, Other: 1, Message: "resource 4 is shadowed by resource 1"},
+			},
+		},
+		"should report unknown shadowing for complex patterns": {
+			res: []Resource{get(`profile/[0-9]+`), get(`^https://api\.example\.com/profile/1$`)},
+			issues: []ValidationIssue{
+				{Kind: IssueShadowUnknown, Resource: 1, Other: 0, Message: "resource 1 might be shadowed by resource 0"},
+			},
+		},
+		"should report empty allowed codes and duplicate names": {
+			res: []Resource{
+				NewResourceWithOptions(get(`users`), ResourceWithName("users")),
+				NewResourceWithOptions(NewResourceStatic(http.MethodPut, regexp.MustCompile(`users`), ms_1), ResourceWithName("users")),
+			},
+			issues: []ValidationIssue{
+				{Kind: IssueDuplicateName, Resource: 1, Other: 0, Message: `resource 1 has the same name "users" as resource 0`},
+				{Kind: IssueNoAllowedCodes, Resource: 1, Other: -1, Message: "resource 1 has no allowed codes"},
+			},
+		},
+	}
+	for tname, tcase := range ttable {
+		tcase := tcase
+		t.Run(tname, func(t *testing.T) {
+			t.Parallel()
+			issues := Validate(NewRoundTripper(http.DefaultTransport, 1, tcase.res...))
+			if !reflect.DeepEqual(tcase.issues, issues) {
+				t.Fatalf("expected validation issues %v but got %v", tcase.issues, issues)
+			}
+			var err error
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						err = r.(error)
+					}
+				}()
+				_ = NewTransport(http.DefaultTransport, WithResources(tcase.res...), WithStrictValidation())
+			}()
+			var ierr ErrTransportInvalid
+			strict := false
+			for _, issue := range tcase.issues {
+				strict = strict || issue.Kind != IssueShadowUnknown
+			}
+			if strict != errors.As(err, &ierr) {
+				t.Fatalf("expected strict validation failure %t but got %v", strict, err)
+			}
+		})
+	}
+}